@@ -5,6 +5,10 @@ import (
 	"time"
 )
 
+// Backoff strategies, see https://aws.amazon.com/cn/blogs/architecture/exponential-backoff-and-jitter/
+// None of them is safe for concurrent use, create one per request.
+
+// BackoffImpl is exponential backoff with full jitter, sleep = random_between(0, min(max, initial * 2 ** attempt))
 type BackoffImpl struct {
 	Initial, Max, cur time.Duration
 }
@@ -14,13 +18,64 @@ func (b *BackoffImpl) Pause() time.Duration {
 		b.cur = b.Initial
 	}
 	interval := time.Duration(1 + rand.Int63n(int64(b.cur)))
-	b.cur = time.Duration(b.cur * 2)
-	if b.cur > b.Max {
-		b.cur = b.Max
-	}
+	b.cur = grow(b.cur, b.Max)
 	return interval
 }
 
 func NewBackoffImpl() *BackoffImpl {
-	return &BackoffImpl{Initial: 100 * time.Millisecond}
+	return &BackoffImpl{Initial: 100 * time.Millisecond, Max: 30 * time.Second}
+}
+
+// EqualJitter keeps half of the exponential delay and randomizes the other half.
+type EqualJitter struct {
+	Initial, Max, cur time.Duration
+}
+
+func (b *EqualJitter) Pause() time.Duration {
+	if b.cur == 0 {
+		b.cur = b.Initial
+	}
+	half := b.cur / 2
+	interval := half + time.Duration(rand.Int63n(int64(b.cur-half)+1))
+	b.cur = grow(b.cur, b.Max)
+	return interval
+}
+
+// DecorrelatedJitter grows from the previous delay, sleep = min(max, random_between(initial, sleep * 3))
+type DecorrelatedJitter struct {
+	Initial, Max, prev time.Duration
+}
+
+func (b *DecorrelatedJitter) Pause() time.Duration {
+	if b.prev == 0 {
+		b.prev = b.Initial
+	}
+	upper := b.prev * 3
+	if upper <= b.Initial {
+		upper = b.Initial + 1
+	}
+	interval := b.Initial + time.Duration(rand.Int63n(int64(upper-b.Initial)))
+	if b.Max > 0 && interval > b.Max {
+		interval = b.Max
+	}
+	b.prev = interval
+	return interval
+}
+
+// Constant always pauses for Interval.
+type Constant struct {
+	Interval time.Duration
+}
+
+func (b *Constant) Pause() time.Duration {
+	return b.Interval
+}
+
+// grow doubles cur, capped at max when max is set.
+func grow(cur, max time.Duration) time.Duration {
+	cur *= 2
+	if max > 0 && cur > max {
+		cur = max
+	}
+	return cur
 }
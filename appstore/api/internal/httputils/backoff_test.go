@@ -0,0 +1,57 @@
+package httputils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	const initial, max = 10 * time.Millisecond, 80 * time.Millisecond
+	tests := []struct {
+		name     string
+		backoff  interface{ Pause() time.Duration }
+		min, max func(attempt int) time.Duration
+	}{
+		{
+			name:    "full jitter",
+			backoff: &BackoffImpl{Initial: initial, Max: max},
+			min:     func(int) time.Duration { return 1 },
+			max:     func(attempt int) time.Duration { return capped(initial<<attempt, max) },
+		},
+		{
+			name:    "equal jitter",
+			backoff: &EqualJitter{Initial: initial, Max: max},
+			min:     func(attempt int) time.Duration { return capped(initial<<attempt, max) / 2 },
+			max:     func(attempt int) time.Duration { return capped(initial<<attempt, max) },
+		},
+		{
+			name:    "decorrelated jitter",
+			backoff: &DecorrelatedJitter{Initial: initial, Max: max},
+			min:     func(int) time.Duration { return initial },
+			max:     func(int) time.Duration { return max },
+		},
+		{
+			name:    "constant",
+			backoff: &Constant{Interval: initial},
+			min:     func(int) time.Duration { return initial },
+			max:     func(int) time.Duration { return initial },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for attempt := 0; attempt < 10; attempt++ {
+				got := tt.backoff.Pause()
+				if got < tt.min(attempt) || got > tt.max(attempt) {
+					t.Errorf("Pause() attempt %d = %v, want in [%v, %v]", attempt, got, tt.min(attempt), tt.max(attempt))
+				}
+			}
+		})
+	}
+}
+
+func capped(d, max time.Duration) time.Duration {
+	if d > max {
+		return max
+	}
+	return d
+}
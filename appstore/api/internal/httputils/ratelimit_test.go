@@ -0,0 +1,145 @@
+package httputils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newTestBucket returns a bucket whose clock only moves with the returned function.
+func newTestBucket(limit int, per time.Duration, burst int) (*TokenBucket, func(time.Duration)) {
+	now := time.Unix(0, 0)
+	b := NewTokenBucket(limit, per, burst)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	b, advance := newTestBucket(1, time.Second, 2)
+
+	for i, want := range []time.Duration{0, 0, time.Second, 2 * time.Second} {
+		if wait, ok := b.reserve(false); !ok || wait != want {
+			t.Errorf("reserve() %d = %v, %v, want %v, true", i, wait, ok, want)
+		}
+	}
+	if b.tokens != -2 {
+		t.Errorf("tokens = %v, want -2 with two reservations queued", b.tokens)
+	}
+
+	advance(3 * time.Second)
+	if wait, ok := b.reserve(false); !ok || wait != 0 {
+		t.Errorf("reserve() after the queue drained = %v, %v, want 0, true", wait, ok)
+	}
+	advance(time.Hour)
+	if _, ok := b.reserve(false); !ok || b.tokens != 1 {
+		t.Errorf("tokens = %v, want refilled up to the burst of 2 less one", b.tokens)
+	}
+}
+
+func TestTokenBucketAllow(t *testing.T) {
+	b, advance := newTestBucket(1, time.Second, 1)
+
+	if !b.Allow() {
+		t.Fatal("Allow() = false, want the burst token")
+	}
+	if b.Allow() {
+		t.Error("Allow() = true on an empty bucket")
+	}
+	if b.tokens != 0 {
+		t.Errorf("tokens = %v, Allow must not queue", b.tokens)
+	}
+	advance(time.Second)
+	if !b.Allow() {
+		t.Error("Allow() = false after a refill")
+	}
+
+	if NewTokenBucket(0, time.Second, 1).Wait(context.Background()) != nil {
+		t.Error("Wait() on a bucket without rate = error, want the burst token")
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "deadline shorter than the wait",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			wantErr: ErrRateLimited,
+		},
+		{
+			name: "canceled while waiting",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBucket(1, time.Hour, 1)
+			if err := b.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait() burst token error = %v", err)
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			if err := b.Wait(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Wait() error = %v, want %v", err, tt.wantErr)
+			}
+			if b.tokens != 0 {
+				t.Errorf("tokens = %v, want the reserved token given back", b.tokens)
+			}
+		})
+	}
+}
+
+func TestTokenBucketWaitBlocks(t *testing.T) {
+	const per = 20 * time.Millisecond
+	b := NewTokenBucket(1, per, 1)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < per/2 {
+		t.Errorf("Wait() returned after %v, want about %v", elapsed, per)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "missing"},
+		{name: "seconds", header: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "negative", header: "-1"},
+		{name: "overflow", header: "99999999999999", want: math.MaxInt64, wantOK: true},
+		{name: "past date", header: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOK: true},
+		{name: "invalid", header: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: make(http.Header)}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			if got, ok := RetryAfter(resp); got != tt.want || ok != tt.wantOK {
+				t.Errorf("RetryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package httputils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// NewTransport returns a transport tuned for the App Store Server API hosts, proxy may be nil for none.
+func NewTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          64,
+		MaxIdleConnsPerHost:   32, // the API is served from a single host per environment
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
+
+var (
+	defaultClient     *http.Client
+	defaultClientOnce sync.Once
+)
+
+// DefaultClient is shared by every client created without an http.Client, instead of http.DefaultClient.
+func DefaultClient() *http.Client {
+	defaultClientOnce.Do(func() {
+		defaultClient = &http.Client{Transport: NewTransport(http.ProxyFromEnvironment)}
+	})
+	return defaultClient
+}
+
+// LimitBody makes reads from body fail with ErrResponseTooLarge after n bytes.
+func LimitBody(body io.ReadCloser, n int64) io.ReadCloser {
+	return &limitedBody{ReadCloser: body, remaining: n}
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// a final read tells a body of exactly n bytes apart from a longer one
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
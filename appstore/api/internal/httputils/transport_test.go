@@ -0,0 +1,32 @@
+package httputils
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		limit   int64
+		wantErr error
+	}{
+		{"under", "abc", 4, nil},
+		{"exact", "abcd", 4, nil},
+		{"over", "abcde", 4, ErrResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(LimitBody(io.NopCloser(strings.NewReader(tt.body)), tt.limit))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadAll() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.body {
+				t.Errorf("ReadAll() = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package appstoreapi
+
+import (
+	"context"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// AppStoreAPI lists the operations of the App Store Server API, it is implemented by Service and
+// by the in-memory fake.Client for tests.
+type AppStoreAPI interface {
+	TransactionInfo(ctx context.Context, bearer, transactionID string, opts ...CallOption) (*datatypes.JWSTransaction, error)
+	TransactionHistory(ctx context.Context, bearer, transactionID string, opts ...CallOption) (*datatypes.HistoryResponse, error)
+	AllSubscriptionStatuses(ctx context.Context, bearer, transactionID string,
+		status datatypes.SubscriptionStatus, opts ...CallOption) (*datatypes.StatusResponse, error)
+	LookUpOrderID(ctx context.Context, bearer, orderID string, opts ...CallOption) (*datatypes.OrderLookupResponse, error)
+	RefundHistory(ctx context.Context, bearer, transactionID, revision string, opts ...CallOption) (*datatypes.RefundHistoryResponse, error)
+	SendConsumptionInformation(ctx context.Context, bearer, transactionID string, cr *datatypes.ConsumptionRequest, opts ...CallOption) error
+	TestNotification(ctx context.Context, bearer string, opts ...CallOption) (*datatypes.SendTestNotificationResponse, error)
+	GetTestNotificationStatus(ctx context.Context, bearer, testNotificationToken string, opts ...CallOption) (*datatypes.NotificationHistoryResponseItem, error)
+	NotificationHistory(ctx context.Context, bearer, paginationToken string,
+		nhr *datatypes.NotificationHistoryRequest, opts ...CallOption) (*datatypes.NotificationHistoryResponse, error)
+}
+
+var _ AppStoreAPI = (*Service)(nil)
@@ -0,0 +1,342 @@
+// Package appstorecache caches the subscription statuses and transactions read from the App Store Server API,
+// entries are dropped when a notification reports a change of their original transaction.
+//
+//	c := appstorecache.New(s, appstorecache.WithTTL(10*time.Minute), appstorecache.WithStaleIfError(24*time.Hour))
+//	status, err := c.AllSubscriptionStatuses(ctx, bearer, transactionID, 0)
+//	...
+//	// in the notifications handler, once the signed payload is verified
+//	err = c.InvalidateNotification(ctx, &notification.Payload)
+package appstorecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+)
+
+// DefaultTTL is the time an entry is served without calling the API unless set with WithTTL.
+const DefaultTTL = 5 * time.Minute
+
+// Entry is a cached response.
+type Entry struct {
+	Value    []byte    // the response encoded as JSON
+	Tags     []string  // the transaction IDs of the response, see Backend.Invalidate
+	StoredAt time.Time // when the response was received
+	Expires  time.Time // after which the entry is never served, the backend may drop it
+}
+
+// Backend stores the entries of a Client, it must be safe for concurrent use.
+type Backend interface {
+	// Get returns the entry of key, nil when there is none.
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Set stores entry under key, replacing the previous one.
+	Set(ctx context.Context, key string, entry *Entry) error
+	// Invalidate removes the entries tagged with tag.
+	Invalidate(ctx context.Context, tag string) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBackend stores entries in b, default an LRU of DefaultLRUSize entries.
+func WithBackend(b Backend) Option {
+	return func(c *Client) {
+		c.backend = b
+	}
+}
+
+// WithTTL serves an entry for d without calling the API, default DefaultTTL.
+func WithTTL(d time.Duration) Option {
+	return func(c *Client) {
+		c.ttl = d
+	}
+}
+
+// WithStaleIfError serves an expired entry for up to d after its TTL when the API can't be reached or fails
+// with a transient error: a transport error, a 5xx, an open circuit or what appstoreapi.IsRetryable retries.
+func WithStaleIfError(d time.Duration) Option {
+	return func(c *Client) {
+		c.staleIfError = d
+	}
+}
+
+// WithKeyPrefix prefixes the keys of the entries, to share a backend between apps. Environments are kept apart
+// by WithEnvironment.
+func WithKeyPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.prefix = prefix
+	}
+}
+
+// WithEnvironment sets the environment of the API, part of the keys and tags of the entries. Default the
+// environment the API reports, as appstoreapi.Service does, else datatypes.Production.
+func WithEnvironment(env datatypes.Environment) Option {
+	return func(c *Client) {
+		c.env = env
+	}
+}
+
+// Client caches the results of TransactionInfo and AllSubscriptionStatuses, the other calls go straight to the API.
+// Calls made with CallOptions aren't cached, as they may change the answer, e.g. appstoreapi.CallEnvironment.
+//
+// A response isn't stored when its transactions were invalidated through the Client while it was fetched,
+// invalidations made through another Client sharing the backend aren't seen.
+type Client struct {
+	appstoreapi.AppStoreAPI
+
+	backend      Backend
+	ttl          time.Duration
+	staleIfError time.Duration
+	prefix       string
+	env          datatypes.Environment
+	nowFunc      func() time.Time
+
+	mu          sync.Mutex        // guards the fields below, and orders storing entries after invalidations
+	generation  uint64            // incremented by every invalidation
+	invalidated map[string]uint64 // the generation each tag was last invalidated at, kept while fetches are in flight
+	inflight    int               // the number of fetches in flight
+}
+
+var _ appstoreapi.AppStoreAPI = (*Client)(nil)
+
+// New returns a Client caching the results of api.
+func New(api appstoreapi.AppStoreAPI, opts ...Option) *Client {
+	c := &Client{
+		AppStoreAPI: api,
+		ttl:         DefaultTTL,
+		nowFunc:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.backend == nil {
+		c.backend = NewLRU(DefaultLRUSize)
+	}
+	if c.env == "" {
+		c.env = datatypes.Production
+		if e, ok := api.(interface{ Environment() datatypes.Environment }); ok && e.Environment() != "" {
+			c.env = e.Environment()
+		}
+	}
+	return c
+}
+
+// TransactionInfo see appstoreapi.Service.TransactionInfo
+func (c *Client) TransactionInfo(ctx context.Context, bearer, transactionID string,
+	opts ...appstoreapi.CallOption) (*datatypes.JWSTransaction, error) {
+	if len(opts) > 0 {
+		return c.AppStoreAPI.TransactionInfo(ctx, bearer, transactionID, opts...)
+	}
+	var out datatypes.JWSTransaction
+	err := c.cached(ctx, string(appstoreapi.EndpointTransactionInfo)+"/"+transactionID, &out, func() (any, []string, error) {
+		t, err := c.AppStoreAPI.TransactionInfo(ctx, bearer, transactionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, tags(transactionID, t.Payload.OriginalTransactionID), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AllSubscriptionStatuses see appstoreapi.Service.AllSubscriptionStatuses
+func (c *Client) AllSubscriptionStatuses(ctx context.Context, bearer, transactionID string,
+	status datatypes.SubscriptionStatus, opts ...appstoreapi.CallOption) (*datatypes.StatusResponse, error) {
+	if len(opts) > 0 {
+		return c.AppStoreAPI.AllSubscriptionStatuses(ctx, bearer, transactionID, status, opts...)
+	}
+	var out datatypes.StatusResponse
+	key := string(appstoreapi.EndpointAllSubscriptionStatuses) + "/" + transactionID + "?status=" + strconv.Itoa(int(status))
+	err := c.cached(ctx, key, &out, func() (any, []string, error) {
+		resp, err := c.AppStoreAPI.AllSubscriptionStatuses(ctx, bearer, transactionID, status)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids := []string{transactionID}
+		for _, group := range resp.Data {
+			for _, last := range group.LastTransactions {
+				ids = append(ids, last.OriginalTransactionID)
+			}
+		}
+		return resp, tags(ids...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Invalidate drops the entries of the transactions of originalTransactionID, and of the transaction with this ID.
+func (c *Client) Invalidate(ctx context.Context, originalTransactionID string) error {
+	return c.invalidate(ctx, c.env, originalTransactionID)
+}
+
+// invalidate drops the entries tagged with id in env, and keeps those being fetched from being stored.
+func (c *Client) invalidate(ctx context.Context, env datatypes.Environment, id string) error {
+	if id == "" {
+		return nil
+	}
+	tag := c.key(env, id)
+	c.mu.Lock()
+	c.generation++
+	if c.inflight > 0 {
+		if c.invalidated == nil {
+			c.invalidated = make(map[string]uint64)
+		}
+		c.invalidated[tag] = c.generation
+	}
+	c.mu.Unlock()
+	return c.backend.Invalidate(ctx, tag)
+}
+
+// InvalidateNotification drops the entries of the original transaction p is about, read from its signed transaction
+// or renewal info. The signature isn't verified, p should come from a verified notification. Notifications without
+// transaction data, e.g. a summary, drop nothing, nor do notifications of another environment.
+func (c *Client) InvalidateNotification(ctx context.Context, p *notifications.ResponseBodyV2DecodedPayload) error {
+	env := c.env
+	if p.Data.Environment != "" {
+		env = datatypes.Environment(p.Data.Environment)
+	}
+	var ids []string
+	if p.Data.SignedTransactionInfo != "" {
+		t, err := appstoreapi.DecodeToJWSTransaction(p.Data.SignedTransactionInfo)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, t.Payload.OriginalTransactionID, t.Payload.TransactionID)
+	}
+	if p.Data.SignedRenewalInfo != "" {
+		r, err := appstoreapi.DecodeToJWSRenewalInfo(p.Data.SignedRenewalInfo)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, r.Payload.OriginalTransactionID)
+	}
+	var errs []error
+	for _, id := range tags(ids...) {
+		errs = append(errs, c.invalidate(ctx, env, id))
+	}
+	return errors.Join(errs...)
+}
+
+// cached decodes into out the entry of key while fresh, or the result of fetch, stored with its tags.
+// Backend failures aren't returned, the call goes to the API instead.
+func (c *Client) cached(ctx context.Context, key string, out any, fetch func() (any, []string, error)) error {
+	key = c.key(c.env, key)
+	now := c.nowFunc()
+	entry, err := c.backend.Get(ctx, key)
+	if err != nil || entry != nil && !now.Before(entry.Expires) {
+		entry = nil
+	}
+	if entry != nil && now.Before(entry.StoredAt.Add(c.ttl)) {
+		if json.Unmarshal(entry.Value, out) == nil {
+			return nil
+		}
+		entry = nil
+	}
+
+	generation := c.begin()
+	defer c.end()
+	v, ids, err := fetch()
+	if err != nil {
+		if entry != nil && isOutage(err) && !errors.Is(ctx.Err(), context.Canceled) {
+			if json.Unmarshal(entry.Value, out) == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	for i := range ids {
+		ids[i] = c.key(c.env, ids[i])
+	}
+	c.store(ctx, generation, key, &Entry{
+		Value:    data,
+		Tags:     ids,
+		StoredAt: now,
+		Expires:  now.Add(c.ttl + c.staleIfError),
+	})
+	return json.Unmarshal(data, out)
+}
+
+// key returns the backend key of s in env.
+func (c *Client) key(env datatypes.Environment, s string) string {
+	return c.prefix + string(env) + "/" + s
+}
+
+// begin counts a fetch in flight and returns the current generation, end must be called once it's over.
+func (c *Client) begin() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inflight++
+	return c.generation
+}
+
+// end counts a fetch done, the invalidations are forgotten once none is in flight.
+func (c *Client) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight--; c.inflight == 0 {
+		c.invalidated = nil
+	}
+}
+
+// store sets entry under key unless one of its tags was invalidated after generation, the fetch of entry may
+// have read the data from before the change. c.mu is held so that an invalidation either is seen here or
+// drops the entry once set.
+func (c *Client) store(ctx context.Context, generation uint64, key string, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range entry.Tags {
+		if c.invalidated[tag] > generation {
+			return
+		}
+	}
+	_ = c.backend.Set(ctx, key, entry)
+}
+
+// isOutage reports whether err says the API is unavailable rather than answering the call, see WithStaleIfError.
+func isOutage(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, appstoreapi.ErrCircuitOpen) || appstoreapi.IsRetryable(nil, err) {
+		return true
+	}
+	var errResp *datatypes.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.HTTPStatus >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// tags returns the distinct non empty ids.
+func tags(ids ...string) []string {
+	var out []string
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,296 @@
+package appstorecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/appstoretest"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	"github.com/gh73962/appleapis/appstore/api/v1/fake"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	renewed := datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1", ProductID: "monthly"}
+	unavailable := fake.Error(datatypes.ErrorCodeGeneralInternalRetryable)
+	notFound := fake.Error(datatypes.ErrorCodeTransactionIDNotFound)
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		steps     func(f *fake.Client, c *Client, clock *time.Time) error
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name: "fresh entry",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				*clock = clock.Add(DefaultTTL - time.Second)
+				return nil
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "expired entry",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				*clock = clock.Add(DefaultTTL)
+				return nil
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "call options bypass the cache",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				_, err := c.AllSubscriptionStatuses(ctx, "bearer", "2", 0, appstoreapi.CallTimeout(time.Second))
+				return err
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "invalidated by original transaction",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				return c.Invalidate(ctx, "1")
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "invalidated by notification",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				ca, err := appstoretest.NewCA()
+				if err != nil {
+					return err
+				}
+				p := notifications.ResponseBodyV2DecodedPayload{NotificationType: notifications.DidRenew}
+				p.Data.SignedTransactionInfo, err = ca.SignTransaction(
+					appstoretest.WithTransactionID("3"), appstoretest.WithOriginalTransactionID("1"))
+				if err != nil {
+					return err
+				}
+				return c.InvalidateNotification(ctx, &p)
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "notification of another subscription",
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				return c.InvalidateNotification(ctx, &notifications.ResponseBodyV2DecodedPayload{})
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "stale during an outage",
+			opts: []Option{WithStaleIfError(time.Hour)},
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				f.FailWith(appstoreapi.EndpointAllSubscriptionStatuses, unavailable)
+				*clock = clock.Add(DefaultTTL + time.Minute)
+				return nil
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "stale while the circuit is open",
+			opts: []Option{WithStaleIfError(time.Hour)},
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				f.FailWith(appstoreapi.EndpointAllSubscriptionStatuses, fmt.Errorf("%w: statuses", appstoreapi.ErrCircuitOpen))
+				*clock = clock.Add(DefaultTTL + time.Minute)
+				return nil
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "stale while the connection is refused",
+			opts: []Option{WithStaleIfError(time.Hour)},
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				f.FailWith(appstoreapi.EndpointAllSubscriptionStatuses, &url.Error{Op: "Get", URL: "https://api.storekit.itunes.apple.com",
+					Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}})
+				*clock = clock.Add(DefaultTTL + time.Minute)
+				return nil
+			},
+			wantCalls: 2,
+		},
+		{
+			name: "too stale during an outage",
+			opts: []Option{WithStaleIfError(time.Hour)},
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				f.FailWith(appstoreapi.EndpointAllSubscriptionStatuses, unavailable)
+				*clock = clock.Add(DefaultTTL + time.Hour)
+				return nil
+			},
+			wantErr:   unavailable,
+			wantCalls: 2,
+		},
+		{
+			name: "stale not served on a final error",
+			opts: []Option{WithStaleIfError(time.Hour)},
+			steps: func(f *fake.Client, c *Client, clock *time.Time) error {
+				f.FailWith(appstoreapi.EndpointAllSubscriptionStatuses, notFound)
+				*clock = clock.Add(DefaultTTL + time.Minute)
+				return nil
+			},
+			wantErr:   notFound,
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := fake.New()
+			f.SetSubscriptionStatus("group", datatypes.Active, renewed, datatypes.JWSRenewalInfoDecodedPayload{})
+			clock := time.Unix(1700000000, 0)
+			c := New(f, tt.opts...)
+			c.nowFunc = func() time.Time { return clock }
+			if lru, ok := c.backend.(*LRU); ok {
+				lru.nowFunc = c.nowFunc
+			}
+
+			if _, err := c.AllSubscriptionStatuses(ctx, "bearer", "2", 0); err != nil {
+				t.Fatalf("AllSubscriptionStatuses() error = %v", err)
+			}
+			if err := tt.steps(f, c, &clock); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.AllSubscriptionStatuses(ctx, "bearer", "2", 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AllSubscriptionStatuses() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (len(got.Data) != 1 || got.Data[0].LastTransactions[0].OriginalTransactionID != "1") {
+				t.Errorf("AllSubscriptionStatuses() = %+v", got)
+			}
+			if calls := f.Calls(appstoreapi.EndpointAllSubscriptionStatuses); calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClientTransactionInfo(t *testing.T) {
+	ctx := context.Background()
+	f := fake.New()
+	f.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1", ProductID: "monthly"})
+	c := New(f, WithKeyPrefix("app/"))
+
+	for i := 0; i < 2; i++ {
+		got, err := c.TransactionInfo(ctx, "bearer", "2")
+		if err != nil || got.Payload.ProductID != "monthly" {
+			t.Fatalf("TransactionInfo() = %+v, %v", got, err)
+		}
+	}
+	if err := c.Invalidate(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TransactionInfo(ctx, "bearer", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := f.Calls(appstoreapi.EndpointTransactionInfo); calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+// invalidatingAPI runs after once, when AllSubscriptionStatuses has read its response.
+type invalidatingAPI struct {
+	appstoreapi.AppStoreAPI
+	after func()
+}
+
+func (a *invalidatingAPI) AllSubscriptionStatuses(ctx context.Context, bearer, transactionID string,
+	status datatypes.SubscriptionStatus, opts ...appstoreapi.CallOption) (*datatypes.StatusResponse, error) {
+	resp, err := a.AppStoreAPI.AllSubscriptionStatuses(ctx, bearer, transactionID, status, opts...)
+	if a.after != nil {
+		after := a.after
+		a.after = nil
+		after()
+	}
+	return resp, err
+}
+
+func TestClientInvalidatedDuringFetch(t *testing.T) {
+	ctx := context.Background()
+	f := fake.New()
+	f.SetSubscriptionStatus("group", datatypes.Active,
+		datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1"}, datatypes.JWSRenewalInfoDecodedPayload{})
+	api := &invalidatingAPI{AppStoreAPI: f}
+	c := New(api)
+	api.after = func() {
+		if err := c.Invalidate(ctx, "1"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.AllSubscriptionStatuses(ctx, "bearer", "2", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := f.Calls(appstoreapi.EndpointAllSubscriptionStatuses); calls != 2 {
+		t.Errorf("calls = %d, want 2, the first response must not be stored", calls)
+	}
+	if c.inflight != 0 || c.invalidated != nil {
+		t.Errorf("inflight = %d, invalidated = %v, want them reset", c.inflight, c.invalidated)
+	}
+}
+
+func TestClientEnvironment(t *testing.T) {
+	ctx := context.Background()
+	f := fake.New()
+	f.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1"})
+	backend := NewLRU(DefaultLRUSize)
+	production := New(f, WithBackend(backend))
+	sandbox := New(f, WithBackend(backend), WithEnvironment(datatypes.Sandbox))
+
+	for _, c := range []*Client{production, sandbox, production, sandbox} {
+		if _, err := c.TransactionInfo(ctx, "bearer", "2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := f.Calls(appstoreapi.EndpointTransactionInfo); calls != 2 {
+		t.Errorf("calls = %d, want one per environment", calls)
+	}
+
+	ca, err := appstoretest.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := notifications.ResponseBodyV2DecodedPayload{NotificationType: notifications.DidRenew}
+	p.Data.Environment = string(datatypes.Sandbox)
+	if p.Data.SignedTransactionInfo, err = ca.SignTransaction(appstoretest.WithOriginalTransactionID("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := production.InvalidateNotification(ctx, &p); err != nil {
+		t.Fatal(err)
+	}
+	if backend.Len() != 1 {
+		t.Errorf("Len() = %d after a sandbox notification, want the production entry kept", backend.Len())
+	}
+
+	if got := New(appstoreapi.NewAppStoreService(ctx, appstoreapi.WithEnvironment(datatypes.Sandbox))).env; got != datatypes.Sandbox {
+		t.Errorf("env = %s, want the environment of the service", got)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if e, _ := l.Get(ctx, key); e == nil {
+			_ = l.Set(ctx, key, &Entry{Value: []byte(key), Tags: []string{"tag-" + key, "all"}})
+		}
+	}
+	if e, _ := l.Get(ctx, "b"); e != nil {
+		t.Errorf("Get(b) = %s, want it evicted", e.Value)
+	}
+	if l.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", l.Len())
+	}
+	_ = l.Invalidate(ctx, "all")
+	if l.Len() != 0 || len(l.tags) != 0 {
+		t.Errorf("Len() = %d, tags = %v after Invalidate", l.Len(), l.tags)
+	}
+}
@@ -0,0 +1,111 @@
+package appstorecache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLRUSize is the number of entries NewLRU keeps when size isn't positive.
+const DefaultLRUSize = 10000
+
+// LRU is an in-memory Backend evicting the least recently used entry once it holds size entries.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of *lruItem, most recently used first
+	items   map[string]*list.Element
+	tags    map[string]map[string]struct{} // tag to keys
+	nowFunc func() time.Time
+}
+
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+// NewLRU returns an empty LRU holding at most size entries.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultLRUSize
+	}
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+		nowFunc: time.Now,
+	}
+}
+
+// Get returns the entry of key, nil once it expired.
+func (l *LRU) Get(_ context.Context, key string) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := e.Value.(*lruItem)
+	if !item.entry.Expires.IsZero() && !l.nowFunc().Before(item.entry.Expires) {
+		l.remove(e)
+		return nil, nil
+	}
+	l.order.MoveToFront(e)
+	entry := item.entry
+	return &entry, nil
+}
+
+// Set stores entry under key, replacing the previous one.
+func (l *LRU) Set(_ context.Context, key string, entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.remove(e)
+	}
+	l.items[key] = l.order.PushFront(&lruItem{key: key, entry: *entry})
+	for _, tag := range entry.Tags {
+		keys, ok := l.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			l.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+// Invalidate removes the entries tagged with tag.
+func (l *LRU) Invalidate(_ context.Context, tag string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.tags[tag] {
+		if e, ok := l.items[key]; ok {
+			l.remove(e)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries held, expired ones included until read or evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// remove drops e and its tags, l.mu is held.
+func (l *LRU) remove(e *list.Element) {
+	item := l.order.Remove(e).(*lruItem)
+	delete(l.items, item.key)
+	for _, tag := range item.entry.Tags {
+		delete(l.tags[tag], item.key)
+		if len(l.tags[tag]) == 0 {
+			delete(l.tags, tag)
+		}
+	}
+}
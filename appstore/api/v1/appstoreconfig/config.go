@@ -0,0 +1,357 @@
+// Package appstoreconfig loads the settings of an App Store Server API client from a JSON or YAML file and
+// APPSTORE_* environment variables, and builds the Service and the token provider they describe.
+//
+//	cfg, err := appstoreconfig.Load("appstore.yaml")
+//	...
+//	s, tokens, err := cfg.NewService(ctx)
+//	bearer, err := tokens.Token()
+//	info, err := s.TransactionInfo(ctx, bearer, transactionID)
+//
+// A file looks like
+//
+//	issuer_id: 57246542-96fe-1a63-e053-0824d011072a
+//	key_id: 2X9R4HXF34
+//	key_path: /etc/appstore/SubscriptionKey_2X9R4HXF34.p8
+//	bundle_id: com.example.app
+//	environment: Sandbox
+//	timeout: 30s
+//	attempt_timeout: 10s
+//	retry:
+//	  enabled: true
+//	  max_attempts: 3
+//	  initial: 100ms
+//	  max: 5s
+package appstoreconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	"github.com/gh73962/appleapis/jwt"
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables overriding the settings of a file, e.g. APPSTORE_KEY_ID.
+const EnvPrefix = "APPSTORE_"
+
+// ErrInvalidConfig is matched by the errors of invalid settings, see FieldError.
+var ErrInvalidConfig = errors.New("invalid appstore config")
+
+// FieldError is an invalid setting, Field is its name in a file or its environment variable.
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return "appstoreconfig: " + e.Field + ": " + e.Msg
+}
+
+func (e *FieldError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// Config describes a client of the App Store Server API, every field can be set by the environment
+// variable named after it, e.g. APPSTORE_RETRY_MAX_ATTEMPTS for retry.max_attempts.
+type Config struct {
+	IssuerID       string                `json:"issuer_id" yaml:"issuer_id"`
+	KeyID          string                `json:"key_id" yaml:"key_id"`
+	KeyPath        string                `json:"key_path" yaml:"key_path"` // of the .p8 file downloaded from App Store Connect
+	Key            string                `json:"key" yaml:"key"`           // PEM content of the .p8 file, instead of KeyPath
+	BundleID       string                `json:"bundle_id" yaml:"bundle_id"`
+	Environment    datatypes.Environment `json:"environment" yaml:"environment"` // default datatypes.Production
+	BaseURL        string                `json:"base_url" yaml:"base_url"`       // see appstoreapi.WithBaseURL, required for LocalTesting
+	UserAgent      string                `json:"user_agent" yaml:"user_agent"`
+	Timeout        Duration              `json:"timeout" yaml:"timeout"`                 // of a call including its retries unless set by CallTimeout, 0 for none
+	AttemptTimeout Duration              `json:"attempt_timeout" yaml:"attempt_timeout"` // of every attempt unless set by CallAttemptTimeout, 0 for none
+	Retry          Retry                 `json:"retry" yaml:"retry"`
+
+	fromEnv map[string]bool // fields set by environment variables, reported by their name
+}
+
+// Retry configures the retries of failed calls, see appstoreapi.WithRetry and appstoreapi.DefaultRetryPolicy.
+type Retry struct {
+	Enabled     bool     `json:"enabled" yaml:"enabled"`
+	MaxAttempts int      `json:"max_attempts" yaml:"max_attempts"` // 0 means bounded only by the timeout
+	Initial     Duration `json:"initial" yaml:"initial"`           // default 100ms
+	Max         Duration `json:"max" yaml:"max"`                   // default 30s
+}
+
+// Duration is a time.Duration written like "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration %s is not a string like \"10s\"", data)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads the file at path, when not empty, overrides its settings with the APPSTORE_* environment variables
+// and validates the result. The format of the file is read from its extension, .json, .yaml or .yml.
+func Load(path string) (*Config, error) {
+	var c Config
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("appstoreconfig: %s: unknown format %q, want .json, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("appstoreconfig: %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides the settings set by the environment variables found with lookup. A key set by the environment
+// replaces the key of the file, whether inline or by path.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	set := func(field string, parse func(v string) error) {
+		v, ok := lookup(envName(field))
+		if !ok {
+			return
+		}
+		if c.fromEnv == nil {
+			c.fromEnv = make(map[string]bool)
+		}
+		c.fromEnv[field] = true
+		if err := parse(v); err != nil {
+			errs = append(errs, &FieldError{Field: envName(field), Msg: err.Error()})
+		}
+	}
+	str := func(p *string) func(string) error {
+		return func(v string) error {
+			*p = v
+			return nil
+		}
+	}
+
+	_, keyPathSet := lookup(envName("key_path"))
+	_, keySet := lookup(envName("key"))
+	if keySet && !keyPathSet {
+		c.KeyPath = ""
+	}
+	if keyPathSet && !keySet {
+		c.Key = ""
+	}
+
+	set("issuer_id", str(&c.IssuerID))
+	set("key_id", str(&c.KeyID))
+	set("key_path", str(&c.KeyPath))
+	set("key", str(&c.Key))
+	set("bundle_id", str(&c.BundleID))
+	set("base_url", str(&c.BaseURL))
+	set("user_agent", str(&c.UserAgent))
+	set("environment", func(v string) error {
+		c.Environment = datatypes.Environment(v)
+		return nil
+	})
+	set("timeout", c.Timeout.parse)
+	set("attempt_timeout", c.AttemptTimeout.parse)
+	set("retry.initial", c.Retry.Initial.parse)
+	set("retry.max", c.Retry.Max.parse)
+	set("retry.enabled", func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		c.Retry.Enabled = b
+		return nil
+	})
+	set("retry.max_attempts", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		c.Retry.MaxAttempts = n
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// envName returns the environment variable setting field, e.g. APPSTORE_RETRY_MAX_ATTEMPTS for retry.max_attempts.
+func envName(field string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(field, ".", "_"))
+}
+
+// fieldName returns the name field is reported by, its environment variable when it set it.
+func (c *Config) fieldName(field string) string {
+	if c.fromEnv[field] {
+		return envName(field)
+	}
+	return field
+}
+
+// keyIDPattern matches the 10 characters identifiers of App Store Connect keys.
+var keyIDPattern = regexp.MustCompile(`^[A-Z0-9]{10}$`)
+
+// Validate checks every setting and returns all the invalid ones, each a *FieldError.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, a ...any) {
+		errs = append(errs, &FieldError{Field: c.fieldName(field), Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if c.IssuerID == "" {
+		invalid("issuer_id", "required, see the Keys page of App Store Connect")
+	}
+	if !keyIDPattern.MatchString(c.KeyID) {
+		invalid("key_id", "%q is not 10 uppercase letters or digits", c.KeyID)
+	}
+	switch {
+	case c.KeyPath == "" && c.Key == "":
+		invalid("key_path", "required unless key is set")
+	case c.KeyPath != "" && c.Key != "":
+		invalid("key", "set only one of key and key_path")
+	default:
+		if _, err := c.PrivateKey(); err != nil {
+			field := "key"
+			if c.KeyPath != "" {
+				field = "key_path"
+			}
+			invalid(field, "%v", err)
+		}
+	}
+	if c.BundleID == "" {
+		invalid("bundle_id", "required")
+	}
+	switch c.Environment {
+	case "", datatypes.Production, datatypes.Sandbox, datatypes.LocalTesting:
+	default:
+		invalid("environment", "%q is not one of %s, %s or %s",
+			c.Environment, datatypes.Production, datatypes.Sandbox, datatypes.LocalTesting)
+	}
+	if c.BaseURL != "" {
+		if _, err := appstoreapi.NormalizeBaseURL(c.BaseURL); err != nil {
+			invalid("base_url", "%v", err)
+		}
+	} else if c.Environment == datatypes.LocalTesting {
+		invalid("base_url", "required for %s, which has no default URL", datatypes.LocalTesting)
+	}
+	if c.Timeout < 0 {
+		invalid("timeout", "%v is negative", time.Duration(c.Timeout))
+	}
+	if c.AttemptTimeout < 0 {
+		invalid("attempt_timeout", "%v is negative", time.Duration(c.AttemptTimeout))
+	} else if c.Timeout > 0 && c.AttemptTimeout > c.Timeout {
+		invalid("attempt_timeout", "%v is longer than timeout %v", time.Duration(c.AttemptTimeout), time.Duration(c.Timeout))
+	}
+	if c.Retry.MaxAttempts < 0 {
+		invalid("retry.max_attempts", "%d is negative", c.Retry.MaxAttempts)
+	}
+	if c.Retry.Initial < 0 {
+		invalid("retry.initial", "%v is negative", time.Duration(c.Retry.Initial))
+	}
+	if c.Retry.Max < 0 {
+		invalid("retry.max", "%v is negative", time.Duration(c.Retry.Max))
+	} else if c.Retry.Max > 0 && c.Retry.Initial > c.Retry.Max {
+		invalid("retry.max", "%v is shorter than retry.initial %v", time.Duration(c.Retry.Max), time.Duration(c.Retry.Initial))
+	}
+	return errors.Join(errs...)
+}
+
+// PrivateKey returns the key read from KeyPath or Key.
+func (c *Config) PrivateKey() (*ecdsa.PrivateKey, error) {
+	if c.KeyPath != "" {
+		return jwt.GetPrivateKeyFromFile(c.KeyPath)
+	}
+	return jwtv5.ParseECPrivateKeyFromPEM([]byte(c.Key))
+}
+
+// Options returns the options of the Service described by c.
+func (c *Config) Options() []appstoreapi.Option {
+	var opts []appstoreapi.Option
+	if c.Environment != "" {
+		opts = append(opts, appstoreapi.WithEnvironment(c.Environment))
+	}
+	if c.BaseURL != "" {
+		opts = append(opts, appstoreapi.WithBaseURL(c.BaseURL))
+	}
+	if c.UserAgent != "" {
+		opts = append(opts, appstoreapi.WithUserAgent(c.UserAgent))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, appstoreapi.WithTimeout(time.Duration(c.Timeout)))
+	}
+	if c.AttemptTimeout > 0 {
+		opts = append(opts, appstoreapi.WithAttemptTimeout(time.Duration(c.AttemptTimeout)))
+	}
+	if c.Retry.Enabled {
+		opts = append(opts, appstoreapi.WithRetry(time.Duration(c.Retry.Initial), time.Duration(c.Retry.Max)))
+		if c.Retry.MaxAttempts > 0 {
+			opts = append(opts, appstoreapi.WithRetryPolicy(&appstoreapi.DefaultRetryPolicy{MaxAttempts: c.Retry.MaxAttempts}))
+		}
+	}
+	return opts
+}
+
+// NewService validates c and returns the Service it describes, with opts applied after its own options, and the
+// provider of the bearer tokens authorizing its calls.
+func (c *Config) NewService(ctx context.Context, opts ...appstoreapi.Option) (*appstoreapi.Service, *jwt.TokenProvider, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	key, err := c.PrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	s := appstoreapi.NewAppStoreService(ctx, append(c.Options(), opts...)...)
+	return s, jwt.NewTokenProvider(c.IssuerID, c.KeyID, c.BundleID, key), nil
+}
@@ -0,0 +1,240 @@
+package appstoreconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/appstoretest"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func writeKey(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	path := filepath.Join(dir, "SubscriptionKey_2X9R4HXF34.p8")
+	if err := os.WriteFile(path, []byte(pemKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, pemKey
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, pemKey := writeKey(t, dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		file       string
+		env        map[string]string
+		want       *Config
+		wantFields []string // of the FieldErrors
+		wantErr    string
+	}{
+		{
+			name: "yaml",
+			file: write("appstore.yaml", `
+issuer_id: issuer
+key_id: 2X9R4HXF34
+key_path: `+keyPath+`
+bundle_id: com.example.app
+environment: Sandbox
+timeout: 30s
+retry:
+  enabled: true
+  max_attempts: 3
+  initial: 100ms
+`),
+			want: &Config{IssuerID: "issuer", KeyID: "2X9R4HXF34", KeyPath: keyPath, BundleID: "com.example.app",
+				Environment: datatypes.Sandbox, Timeout: Duration(30 * time.Second),
+				Retry: Retry{Enabled: true, MaxAttempts: 3, Initial: Duration(100 * time.Millisecond)}},
+		},
+		{
+			name: "json overridden by the environment",
+			file: write("appstore.json", `{"issuer_id": "issuer", "key_id": "2X9R4HXF34", "key_path": "`+keyPath+`",
+				"bundle_id": "com.example.app", "attempt_timeout": "5s"}`),
+			env: map[string]string{"APPSTORE_BUNDLE_ID": "com.example.other", "APPSTORE_RETRY_ENABLED": "true",
+				"APPSTORE_ATTEMPT_TIMEOUT": "2s"},
+			want: &Config{IssuerID: "issuer", KeyID: "2X9R4HXF34", KeyPath: keyPath, BundleID: "com.example.other",
+				AttemptTimeout: Duration(2 * time.Second), Retry: Retry{Enabled: true}},
+		},
+		{
+			name: "environment only with an inline key",
+			env: map[string]string{"APPSTORE_ISSUER_ID": "issuer", "APPSTORE_KEY_ID": "2X9R4HXF34", "APPSTORE_KEY": pemKey,
+				"APPSTORE_BUNDLE_ID": "com.example.app", "APPSTORE_ENVIRONMENT": "LocalTesting",
+				"APPSTORE_BASE_URL": "http://localhost:8080/inApps/v1/"},
+			want: &Config{IssuerID: "issuer", KeyID: "2X9R4HXF34", Key: pemKey, BundleID: "com.example.app",
+				Environment: datatypes.LocalTesting, BaseURL: "http://localhost:8080/inApps/v1/"},
+		},
+		{
+			name: "key of the environment replaces the key path of the file",
+			file: write("keypath.json", `{"issuer_id": "issuer", "key_id": "2X9R4HXF34", "key_path": "`+keyPath+`",
+				"bundle_id": "com.example.app"}`),
+			env:  map[string]string{"APPSTORE_KEY": pemKey},
+			want: &Config{IssuerID: "issuer", KeyID: "2X9R4HXF34", Key: pemKey, BundleID: "com.example.app"},
+		},
+		{
+			name: "local testing without base URL",
+			file: write("local.yaml", `
+issuer_id: issuer
+key_id: 2X9R4HXF34
+key_path: `+keyPath+`
+bundle_id: com.example.app
+environment: LocalTesting
+`),
+			wantFields: []string{"base_url"},
+		},
+		{
+			name: "invalid settings of the environment",
+			file: write("valid.yaml", `
+issuer_id: issuer
+key_id: 2X9R4HXF34
+key_path: `+keyPath+`
+bundle_id: com.example.app
+`),
+			env:        map[string]string{"APPSTORE_KEY_ID": "key", "APPSTORE_ENVIRONMENT": "production"},
+			wantFields: []string{"APPSTORE_KEY_ID", "APPSTORE_ENVIRONMENT"},
+		},
+		{
+			name: "invalid settings",
+			file: write("invalid.yaml", `
+key_id: key
+key_path: `+filepath.Join(dir, "missing.p8")+`
+environment: production
+base_url: localhost:8080
+timeout: 1s
+attempt_timeout: 2s
+retry:
+  initial: 2s
+  max: 1s
+`),
+			wantFields: []string{"issuer_id", "key_id", "key_path", "bundle_id", "environment", "base_url",
+				"attempt_timeout", "retry.max"},
+		},
+		{
+			name:       "both keys",
+			env:        map[string]string{"APPSTORE_ISSUER_ID": "issuer", "APPSTORE_KEY_ID": "2X9R4HXF34", "APPSTORE_KEY": pemKey, "APPSTORE_KEY_PATH": keyPath, "APPSTORE_BUNDLE_ID": "b"},
+			wantFields: []string{"APPSTORE_KEY"},
+		},
+		{
+			name:       "invalid environment variables",
+			env:        map[string]string{"APPSTORE_TIMEOUT": "30", "APPSTORE_RETRY_MAX_ATTEMPTS": "three"},
+			wantFields: []string{"APPSTORE_TIMEOUT", "APPSTORE_RETRY_MAX_ATTEMPTS"},
+		},
+		{
+			name:    "unknown field",
+			file:    write("typo.yaml", "issuer: issuer\n"),
+			wantErr: "field issuer not found",
+		},
+		{
+			name:    "unknown format",
+			file:    write("appstore.toml", ""),
+			wantErr: `unknown format ".toml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			got, err := Load(tt.file)
+			if tt.want != nil {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				got.fromEnv = nil
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Load() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Load() error = nil")
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Load() error = %v, want %v", err, ErrInvalidConfig)
+			}
+			var fields []string
+			for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var fieldErr *FieldError
+				if errors.As(err, &fieldErr) {
+					fields = append(fields, fieldErr.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Load() fields = %v, want %v\n%v", fields, tt.wantFields, err)
+			}
+		})
+	}
+}
+
+func TestConfigNewService(t *testing.T) {
+	_, pemKey := writeKey(t, t.TempDir())
+	srv := appstoretest.NewServer()
+	defer srv.Close()
+	srv.AddTransaction(appstoretest.NewTransaction(appstoretest.WithTransactionID("1")))
+
+	cfg := Config{IssuerID: "issuer", KeyID: "2X9R4HXF34", Key: pemKey, BundleID: srv.BundleID,
+		Environment: datatypes.LocalTesting, BaseURL: srv.BaseURL(), Timeout: Duration(time.Second)}
+	ctx := context.Background()
+	s, tokens, err := cfg.NewService(ctx, srv.Options()...)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	bearer, err := tokens.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.TransactionInfo(ctx, bearer, "1"); err != nil || info.Payload.TransactionID != "1" {
+		t.Errorf("TransactionInfo() = %+v, %v", info, err)
+	}
+
+	cfg.Timeout = Duration(20 * time.Millisecond)
+	srv.InjectFault(appstoreapi.EndpointTransactionInfo, appstoretest.Fault{Delay: 100 * time.Millisecond})
+	if s, _, err = cfg.NewService(ctx, srv.Options()...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TransactionInfo(ctx, bearer, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TransactionInfo() error = %v, want the configured timeout", err)
+	}
+	if _, err := s.TransactionInfo(ctx, bearer, "1", appstoreapi.CallTimeout(time.Second)); err != nil {
+		t.Errorf("TransactionInfo() with a longer CallTimeout error = %v", err)
+	}
+
+	cfg.KeyID = ""
+	if _, _, err := cfg.NewService(ctx); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewService() error = %v, want %v", err, ErrInvalidConfig)
+	}
+}
@@ -0,0 +1,169 @@
+// Package appstoreregistry keeps the clients and credentials of several apps, possibly of several developer
+// accounts, and routes signed transactions and notifications to the app of their bundle ID and environment.
+// An app answering both production and sandbox notifications is registered once per environment.
+//
+//	r := appstoreregistry.New()
+//	_, err := r.Register(ctx, appstoreregistry.AppConfig{BundleID: "com.example.app", Credentials: creds})
+//	...
+//	app, notification, err := r.ForNotification(body.SignedPayload)
+//	bearer, err := app.Bearer()
+//	status, err := app.Service.AllSubscriptionStatuses(ctx, bearer, transactionID, 0)
+package appstoreregistry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+	"github.com/gh73962/appleapis/jwt"
+)
+
+var (
+	// ErrUnknownApp is returned for a bundle ID and environment no app is registered for.
+	ErrUnknownApp = errors.New("unknown app")
+	// ErrDuplicateApp is returned when registering a bundle ID twice in the same environment.
+	ErrDuplicateApp = errors.New("app already registered")
+	// ErrInvalidConfig is returned when registering an app without a bundle ID or credentials.
+	ErrInvalidConfig = errors.New("invalid app config")
+)
+
+// Credentials is an App Store Server API key of a developer account, it may be shared by the apps of the account.
+// see https://developer.apple.com/documentation/appstoreserverapi/creating_api_keys_to_use_with_the_app_store_server_api
+type Credentials struct {
+	IssuerID   string
+	KeyID      string
+	PrivateKey *ecdsa.PrivateKey // see jwt.GetPrivateKeyFromFile
+}
+
+// AppConfig describes an app to register.
+type AppConfig struct {
+	BundleID    string
+	Credentials Credentials
+	Environment datatypes.Environment // default datatypes.Production
+	Options     []appstoreapi.Option  // applied after the environment
+}
+
+// App is a registered app, its Service calls the API in the app's environment.
+type App struct {
+	BundleID    string
+	Environment datatypes.Environment
+	Service     *appstoreapi.Service
+
+	tokens *jwt.TokenProvider
+}
+
+// Bearer returns a token authorizing the calls of the app, reused until shortly before it expires.
+func (a *App) Bearer() (string, error) {
+	return a.tokens.Token()
+}
+
+// Registry holds the registered apps by bundle ID and environment, it is safe for concurrent use.
+type Registry struct {
+	mu   sync.RWMutex
+	apps map[appKey]*App
+}
+
+type appKey struct {
+	bundleID    string
+	environment datatypes.Environment
+}
+
+// New returns an empty Registry.
+func New() *Registry {
+	return &Registry{apps: make(map[appKey]*App)}
+}
+
+// Register builds the Service of the app described by cfg and adds it to r, an invalid option is returned
+// by the calls of the Service like for NewAppStoreService.
+func (r *Registry) Register(ctx context.Context, cfg AppConfig) (*App, error) {
+	if cfg.BundleID == "" || cfg.Credentials.IssuerID == "" || cfg.Credentials.KeyID == "" || cfg.Credentials.PrivateKey == nil {
+		return nil, fmt.Errorf("%w: %q needs a bundle ID, an issuer ID, a key ID and a private key", ErrInvalidConfig, cfg.BundleID)
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = datatypes.Production
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := appKey{cfg.BundleID, cfg.Environment}
+	if _, ok := r.apps[key]; ok {
+		return nil, fmt.Errorf("%w: %s in %s", ErrDuplicateApp, cfg.BundleID, cfg.Environment)
+	}
+	opts := append([]appstoreapi.Option{appstoreapi.WithEnvironment(cfg.Environment)}, cfg.Options...)
+	app := &App{
+		BundleID:    cfg.BundleID,
+		Environment: cfg.Environment,
+		Service:     appstoreapi.NewAppStoreService(ctx, opts...),
+		tokens:      jwt.NewTokenProvider(cfg.Credentials.IssuerID, cfg.Credentials.KeyID, cfg.BundleID, cfg.Credentials.PrivateKey),
+	}
+	r.apps[key] = app
+	return app, nil
+}
+
+// App returns the app registered for bundleID in env, an empty env is datatypes.Production.
+func (r *Registry) App(bundleID string, env datatypes.Environment) (*App, error) {
+	if env == "" {
+		env = datatypes.Production
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	app, ok := r.apps[appKey{bundleID, env}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q in %s", ErrUnknownApp, bundleID, env)
+	}
+	return app, nil
+}
+
+// BundleIDs returns the bundle IDs of the registered apps, sorted, each once whatever its environments.
+func (r *Registry) BundleIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[string]bool, len(r.apps))
+	ids := make([]string, 0, len(r.apps))
+	for key := range r.apps {
+		if !seen[key.bundleID] {
+			seen[key.bundleID] = true
+			ids = append(ids, key.bundleID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ForTransaction decodes the signed transaction and returns the app of its bundle ID and environment.
+// The signature isn't verified.
+func (r *Registry) ForTransaction(signedTransaction string) (*App, *datatypes.JWSTransaction, error) {
+	t, err := appstoreapi.DecodeToJWSTransaction(signedTransaction)
+	if err != nil {
+		return nil, nil, err
+	}
+	app, err := r.App(t.Payload.BundleID, t.Payload.Environment)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, t, nil
+}
+
+// ForNotification decodes the signed payload of a notification and returns the app of its bundle ID and
+// environment, read from its data or, for a summary, from its summary. The signature isn't verified.
+func (r *Registry) ForNotification(signedPayload string) (*App, *notifications.JWSNotification, error) {
+	n, err := notifications.DecodeToJWSNotification(signedPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundleID, env := n.Payload.Data.BundleID, n.Payload.Data.Environment
+	if bundleID == "" {
+		bundleID, env = n.Payload.Summary.BundleID, n.Payload.Summary.Environment
+	}
+	app, err := r.App(bundleID, datatypes.Environment(env))
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, n, nil
+}
@@ -0,0 +1,203 @@
+package appstoreregistry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/appstoretest"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+)
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := Credentials{IssuerID: "issuer", KeyID: "KEY1", PrivateKey: key}
+	srv := appstoretest.NewServer()
+	defer srv.Close()
+	srv.AddTransaction(appstoretest.NewTransaction(appstoretest.WithTransactionID("1"), appstoretest.WithBundleID("com.example.b")))
+
+	r := New()
+	for _, cfg := range []AppConfig{
+		{BundleID: "com.example.a", Credentials: creds},
+		{BundleID: "com.example.a", Credentials: creds, Environment: datatypes.Sandbox},
+		{BundleID: "com.example.b", Credentials: creds, Environment: datatypes.LocalTesting,
+			Options: []appstoreapi.Option{appstoreapi.WithBaseURL(srv.BaseURL()), appstoreapi.WithHTTPClient(srv.Client())}},
+	} {
+		if _, err := r.Register(ctx, cfg); err != nil {
+			t.Fatalf("Register(%s) error = %v", cfg.BundleID, err)
+		}
+	}
+	if _, err := r.Register(ctx, AppConfig{BundleID: "com.example.a", Credentials: creds}); !errors.Is(err, ErrDuplicateApp) {
+		t.Errorf("Register(duplicate) error = %v, want %v", err, ErrDuplicateApp)
+	}
+	if _, err := r.Register(ctx, AppConfig{BundleID: "com.example.c"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Register(no credentials) error = %v, want %v", err, ErrInvalidConfig)
+	}
+	if got := r.BundleIDs(); !reflect.DeepEqual(got, []string{"com.example.a", "com.example.b"}) {
+		t.Errorf("BundleIDs() = %v", got)
+	}
+
+	ca, err := appstoretest.NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := appstoretest.NewTransaction(appstoretest.WithTransactionID("1"), appstoretest.WithBundleID("com.example.b"),
+		appstoretest.WithEnvironment(datatypes.LocalTesting))
+	sandboxTx := appstoretest.NewTransaction(appstoretest.WithBundleID("com.example.a"), appstoretest.WithEnvironment(datatypes.Sandbox))
+	sandboxOnly := appstoretest.NewTransaction(appstoretest.WithBundleID("com.example.b"), appstoretest.WithEnvironment(datatypes.Sandbox))
+	other := appstoretest.NewTransaction(appstoretest.WithBundleID("com.example.other"))
+	tests := []struct {
+		name     string
+		route    func() (*App, error)
+		wantApp  string
+		wantEnv  datatypes.Environment
+		wantErr  error
+		wantCall bool
+	}{
+		{
+			name: "transaction",
+			route: func() (*App, error) {
+				app, _, err := r.ForTransaction(appstoretest.Must(ca.SignTransaction(appstoretest.WithBundleID("com.example.a"),
+					appstoretest.WithEnvironment(datatypes.Production))))
+				return app, err
+			},
+			wantApp: "com.example.a",
+			wantEnv: datatypes.Production,
+		},
+		{
+			name: "sandbox transaction",
+			route: func() (*App, error) {
+				app, _, err := r.ForTransaction(appstoretest.Must(ca.SignTransaction(appstoretest.WithBundleID("com.example.a"))))
+				return app, err
+			},
+			wantApp: "com.example.a",
+			wantEnv: datatypes.Sandbox,
+		},
+		{
+			name: "sandbox notification",
+			route: func() (*App, error) {
+				app, _, err := r.ForNotification(appstoretest.Must(ca.SignNotification(notifications.DidRenew, "", &sandboxTx, nil)))
+				return app, err
+			},
+			wantApp: "com.example.a",
+			wantEnv: datatypes.Sandbox,
+		},
+		{
+			name: "environment not registered",
+			route: func() (*App, error) {
+				app, _, err := r.ForNotification(appstoretest.Must(ca.SignNotification(notifications.DidRenew, "", &sandboxOnly, nil)))
+				return app, err
+			},
+			wantErr: ErrUnknownApp,
+		},
+		{
+			name: "notification",
+			route: func() (*App, error) {
+				app, n, err := r.ForNotification(appstoretest.Must(ca.SignNotification(notifications.DidRenew, "", &tx, nil)))
+				if err == nil && n.Payload.NotificationType != notifications.DidRenew {
+					t.Errorf("ForNotification() = %+v", n.Payload)
+				}
+				return app, err
+			},
+			wantApp:  "com.example.b",
+			wantEnv:  datatypes.LocalTesting,
+			wantCall: true,
+		},
+		{
+			name: "summary notification",
+			route: func() (*App, error) {
+				p := notifications.ResponseBodyV2DecodedPayload{NotificationType: notifications.RenewalExtension}
+				p.Summary.BundleID = "com.example.a"
+				p.Summary.Environment = string(datatypes.Production)
+				app, _, err := r.ForNotification(appstoretest.Must(ca.Sign(p)))
+				return app, err
+			},
+			wantApp: "com.example.a",
+			wantEnv: datatypes.Production,
+		},
+		{
+			name: "unknown app",
+			route: func() (*App, error) {
+				app, _, err := r.ForNotification(appstoretest.Must(ca.SignNotification(notifications.DidRenew, "", &other, nil)))
+				return app, err
+			},
+			wantErr: ErrUnknownApp,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := tt.route()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("route error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if app.BundleID != tt.wantApp || app.Environment != tt.wantEnv {
+				t.Errorf("routed to %s in %s, want %s in %s", app.BundleID, app.Environment, tt.wantApp, tt.wantEnv)
+			}
+			if !tt.wantCall {
+				return
+			}
+			bearer, err := app.Bearer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info, err := app.Service.TransactionInfo(ctx, bearer, "1"); err != nil || info.Payload.BundleID != app.BundleID {
+				t.Errorf("TransactionInfo() = %+v, %v", info, err)
+			}
+		})
+	}
+}
+
+func TestAppBearer(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	app, err := r.Register(context.Background(), AppConfig{
+		BundleID:    "com.example.a",
+		Credentials: Credentials{IssuerID: "issuer", KeyID: "KEY1", PrivateKey: key},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Unix(1700000000, 0)
+	app.tokens.Now = func() time.Time { return clock }
+
+	first, err := app.Bearer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwtv5.Parse(first, func(*jwtv5.Token) (any, error) { return &key.PublicKey, nil },
+		jwtv5.WithTimeFunc(func() time.Time { return clock }))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	claims := token.Claims.(jwtv5.MapClaims)
+	if claims["bid"] != "com.example.a" || claims["iss"] != "issuer" || token.Header["kid"] != "KEY1" {
+		t.Errorf("bearer header = %v, claims = %v", token.Header, claims)
+	}
+
+	clock = clock.Add(20 * time.Minute)
+	if again, _ := app.Bearer(); again != first {
+		t.Error("Bearer() not reused before it expires")
+	}
+	clock = clock.Add(6 * time.Minute)
+	if renewed, _ := app.Bearer(); renewed == first {
+		t.Error("Bearer() reused shortly before it expires")
+	}
+}
@@ -0,0 +1,135 @@
+package appstoretest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+)
+
+// Marker extensions Apple sets on the certificates signing App Store data, verifiers commonly check them.
+var (
+	oidAppleIntermediate = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+	oidAppleLeaf         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+)
+
+// CA is a throwaway root, intermediate and leaf chain shaped like Apple's, the leaf signs data as ES256 JWS
+// with the chain in the x5c header.
+type CA struct {
+	Root         *x509.Certificate
+	Intermediate *x509.Certificate
+	Leaf         *x509.Certificate
+
+	key *ecdsa.PrivateKey // of Leaf
+	x5c []string
+}
+
+// NewCA generates a chain valid for a year.
+func NewCA() (*CA, error) {
+	now := time.Now()
+	notAfter := now.AddDate(1, 0, 0)
+
+	rootKey, root, err := newCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Apple Root CA - G3", Organization: []string{"appstoretest"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	intermediateKey, intermediate, err := newCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Apple Worldwide Developer Relations Certification Authority", Organization: []string{"appstoretest"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAppleIntermediate, Value: asn1.NullBytes}},
+	}, root, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	leafKey, leaf, err := newCertificate(&x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Test Prod ECC Mac App Store and iTunes Store Receipt Signing", Organization: []string{"appstoretest"}},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleLeaf, Value: asn1.NullBytes}},
+	}, intermediate, intermediateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{
+		Root:         root,
+		Intermediate: intermediate,
+		Leaf:         leaf,
+		key:          leafKey,
+		x5c: []string{
+			base64.StdEncoding.EncodeToString(leaf.Raw),
+			base64.StdEncoding.EncodeToString(intermediate.Raw),
+			base64.StdEncoding.EncodeToString(root.Raw),
+		},
+	}, nil
+}
+
+// newCertificate signs template with parentKey, or self-signs it when parent is nil.
+func newCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*ecdsa.PrivateKey, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// Roots returns a pool holding Root, to verify the x5c chain of data signed by ca.
+func (ca *CA) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Root)
+	return pool
+}
+
+// Sign encodes payload as JSON and signs it as ES256 JWS compact serialization.
+func (ca *CA) Sign(payload any) (string, error) {
+	header, err := json.Marshal(map[string]any{"alg": "ES256", "x5c": ca.x5c})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signingString := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := jwtv5.SigningMethodES256.Sign(signingString, ca.key)
+	if err != nil {
+		return "", err
+	}
+	return signingString + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,201 @@
+package appstoretest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// Step is a scripted answer of a FaultTransport to one request.
+type Step struct {
+	Err      error         // returned instead of a response when not nil
+	Status   int           // 200 when 0
+	Header   http.Header   // of the response
+	Body     string        // of the response
+	Delay    time.Duration // before the response headers, cut short by the request context
+	Truncate bool          // reading the body fails with io.ErrUnexpectedEOF half way
+}
+
+// OK answers 200 with body.
+func OK(body string) Step {
+	return Step{Status: http.StatusOK, Body: body}
+}
+
+// Status answers code without a body, e.g. Status(http.StatusServiceUnavailable).
+func Status(code int) Step {
+	return Step{Status: code}
+}
+
+// RetryAfter answers code asking to retry after d, rounded up to seconds.
+func RetryAfter(code int, d time.Duration) Step {
+	secs := int((d + time.Second - 1) / time.Second)
+	return Step{Status: code, Header: http.Header{"Retry-After": {strconv.Itoa(secs)}}}
+}
+
+// AppleError answers the App Store error code, e.g. AppleError(4040002).
+func AppleError(code int64) Step {
+	body, _ := json.Marshal(datatypes.ErrorResponse{ErrorCode: code, ErrorMessage: "injected"})
+	return Step{
+		Status: int(code / 10000),
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   string(body),
+	}
+}
+
+// ConnReset fails like a connection reset by the server.
+func ConnReset() Step {
+	return Step{Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}
+}
+
+// UnexpectedEOF fails like a connection closed before the response headers.
+func UnexpectedEOF() Step {
+	return Step{Err: io.ErrUnexpectedEOF}
+}
+
+// TruncatedBody answers 200 with body, reading it fails half way.
+func TruncatedBody(body string) Step {
+	return Step{Status: http.StatusOK, Body: body, Truncate: true}
+}
+
+// SlowHeaders answers step after d, or fails with the request context error if it ends first.
+func SlowHeaders(d time.Duration, step Step) Step {
+	step.Delay = d
+	return step
+}
+
+// FaultTransport is an http.RoundTripper answering requests with scripted steps, to exercise retries and timeouts.
+//
+//	ft := appstoretest.NewFaultTransport(appstoretest.ConnReset(), appstoretest.AppleError(5000001), appstoretest.OK(`{}`))
+//	s := appstoreapi.NewAppStoreService(ctx, appstoreapi.WithHTTPClient(ft.Client()), appstoreapi.WithRetry(...))
+//	...
+//	ft.AssertAttempts(t, 3)
+type FaultTransport struct {
+	// Default answers the requests after the script ran out, 200 with an empty JSON object when zero.
+	Default Step
+
+	mu       sync.Mutex
+	steps    []Step
+	attempts []time.Time
+}
+
+// NewFaultTransport answers the requests in order with steps.
+func NewFaultTransport(steps ...Step) *FaultTransport {
+	return &FaultTransport{steps: steps}
+}
+
+// Client returns an http.Client sending requests through f.
+func (f *FaultTransport) Client() *http.Client {
+	return &http.Client{Transport: f}
+}
+
+func (f *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	f.mu.Lock()
+	f.attempts = append(f.attempts, time.Now())
+	step := f.Default
+	if len(f.steps) > 0 {
+		step = f.steps[0]
+		f.steps = f.steps[1:]
+	} else if step.Status == 0 && step.Err == nil {
+		step = OK(`{}`)
+	}
+	f.mu.Unlock()
+
+	if step.Delay > 0 {
+		t := time.NewTimer(step.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := step.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	var body io.Reader = strings.NewReader(step.Body)
+	if step.Truncate {
+		body = io.MultiReader(strings.NewReader(step.Body[:len(step.Body)/2]), eofReader{})
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(body),
+		ContentLength: int64(len(step.Body)),
+		Request:       req,
+	}, nil
+}
+
+// eofReader fails like a connection closed in the middle of a body.
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+// Attempts returns the number of requests made so far.
+func (f *FaultTransport) Attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.attempts)
+}
+
+// Delays returns the time between the starts of consecutive requests.
+func (f *FaultTransport) Delays() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var delays []time.Duration
+	for i := 1; i < len(f.attempts); i++ {
+		delays = append(delays, f.attempts[i].Sub(f.attempts[i-1]))
+	}
+	return delays
+}
+
+// AssertAttempts fails t unless want requests were made.
+func (f *FaultTransport) AssertAttempts(t testing.TB, want int) {
+	t.Helper()
+	if got := f.Attempts(); got != want {
+		t.Errorf("attempts = %d, want %d", got, want)
+	}
+}
+
+// AssertDelays fails t unless the delays between requests are want, each within [want, want+tolerance].
+func (f *FaultTransport) AssertDelays(t testing.TB, tolerance time.Duration, want ...time.Duration) {
+	t.Helper()
+	got := f.Delays()
+	if len(got) != len(want) {
+		t.Errorf("delays = %v, want %v", got, want)
+		return
+	}
+	for i := range want {
+		if got[i] < want[i] || got[i] > want[i]+tolerance {
+			t.Errorf("delay %d = %v, want %v (+%v)", i+1, got[i], want[i], tolerance)
+		}
+	}
+}
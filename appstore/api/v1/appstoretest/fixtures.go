@@ -0,0 +1,275 @@
+package appstoretest
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+)
+
+// TransactionOption sets fields of a transaction minted by NewTransaction.
+type TransactionOption func(*datatypes.JWSTransactionDecodedPayload)
+
+// RenewalOption sets fields of renewal info minted by NewRenewalInfo.
+type RenewalOption func(*datatypes.JWSRenewalInfoDecodedPayload)
+
+// NewTransaction returns a monthly auto-renewable subscription purchased now in the sandbox,
+// with random identifiers, changed by opts.
+func NewTransaction(opts ...TransactionOption) datatypes.JWSTransactionDecodedPayload {
+	now := time.Now()
+	id := newID()
+	t := datatypes.JWSTransactionDecodedPayload{
+		BundleID:                    "com.example.app",
+		Environment:                 datatypes.Sandbox,
+		ExpiresDate:                 now.AddDate(0, 1, 0).UnixMilli(),
+		InAppOwnershipType:          datatypes.Purchased,
+		OriginalPurchaseDate:        now.UnixMilli(),
+		OriginalTransactionID:       id,
+		ProductID:                   "com.example.app.monthly",
+		PurchaseDate:                now.UnixMilli(),
+		Quantity:                    1,
+		SignedDate:                  now.UnixMilli(),
+		Storefront:                  "USA",
+		StorefrontID:                "143441",
+		SubscriptionGroupIdentifier: "21000000",
+		TransactionID:               id,
+		TransactionReason:           "PURCHASE",
+		Type:                        datatypes.AutoRenewableSubscription,
+		WebOrderLineItemID:          newID(),
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+// WithTransactionID sets the transaction ID, and the original one of a first purchase.
+func WithTransactionID(id string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		if t.OriginalTransactionID == t.TransactionID {
+			t.OriginalTransactionID = id
+		}
+		t.TransactionID = id
+	}
+}
+
+// WithOriginalTransactionID makes the transaction a renewal or restore of id.
+func WithOriginalTransactionID(id string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.OriginalTransactionID = id
+		t.TransactionReason = "RENEWAL"
+	}
+}
+
+func WithBundleID(bundleID string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.BundleID = bundleID
+	}
+}
+
+func WithProductID(productID string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.ProductID = productID
+	}
+}
+
+func WithEnvironment(env datatypes.Environment) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.Environment = env
+	}
+}
+
+// WithType sets the product type, the subscription fields are cleared for other types.
+func WithType(typ datatypes.TransactionType) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.Type = typ
+		if typ != datatypes.AutoRenewableSubscription {
+			t.ExpiresDate = 0
+			t.SubscriptionGroupIdentifier = ""
+			t.WebOrderLineItemID = ""
+		}
+	}
+}
+
+// WithPurchaseDate sets the purchase date, a subscription keeps its period length.
+func WithPurchaseDate(date time.Time) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		if t.ExpiresDate != 0 {
+			t.ExpiresDate += date.UnixMilli() - t.PurchaseDate
+		}
+		if t.OriginalPurchaseDate == t.PurchaseDate {
+			t.OriginalPurchaseDate = date.UnixMilli()
+		}
+		t.PurchaseDate = date.UnixMilli()
+	}
+}
+
+func WithExpiresDate(date time.Time) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.ExpiresDate = date.UnixMilli()
+	}
+}
+
+// WithRevocation marks the transaction refunded or revoked at date for reason, 0 for another reason, 1 for an app issue.
+func WithRevocation(date time.Time, reason int) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.RevocationDate = date.UnixMilli()
+		t.RevocationReason = reason
+	}
+}
+
+func WithAppAccountToken(token string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.AppAccountToken = token
+	}
+}
+
+func WithOffer(typ datatypes.OfferType, identifier string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.OfferType = typ
+		t.OfferIdentifier = identifier
+	}
+}
+
+func WithOwnership(ownership datatypes.InAppOwnershipType) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.InAppOwnershipType = ownership
+	}
+}
+
+func WithStorefront(storefront, storefrontID string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.Storefront = storefront
+		t.StorefrontID = storefrontID
+	}
+}
+
+func WithSubscriptionGroup(groupID string) TransactionOption {
+	return func(t *datatypes.JWSTransactionDecodedPayload) {
+		t.SubscriptionGroupIdentifier = groupID
+	}
+}
+
+// NewRenewalInfo returns the renewal info of the subscription t, renewing automatically at its expiry, changed by opts.
+func NewRenewalInfo(t datatypes.JWSTransactionDecodedPayload, opts ...RenewalOption) datatypes.JWSRenewalInfoDecodedPayload {
+	r := datatypes.JWSRenewalInfoDecodedPayload{
+		AutoRenewProductID:          t.ProductID,
+		AutoRenewStatus:             1,
+		Environment:                 t.Environment,
+		OriginalTransactionID:       t.OriginalTransactionID,
+		ProductID:                   t.ProductID,
+		RecentSubscriptionStartDate: t.OriginalPurchaseDate,
+		RenewalDate:                 t.ExpiresDate,
+		SignedDate:                  time.Now().UnixMilli(),
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+// WithAutoRenewOff turns automatic renewal off, the subscription expires for intent.
+func WithAutoRenewOff(intent datatypes.ExpirationIntent) RenewalOption {
+	return func(r *datatypes.JWSRenewalInfoDecodedPayload) {
+		r.AutoRenewStatus = 0
+		r.ExpirationIntent = intent
+	}
+}
+
+// WithAutoRenewProductID renews into productID, e.g. after a downgrade.
+func WithAutoRenewProductID(productID string) RenewalOption {
+	return func(r *datatypes.JWSRenewalInfoDecodedPayload) {
+		r.AutoRenewProductID = productID
+	}
+}
+
+// WithBillingRetry puts the subscription in billing retry, with a grace period until gracePeriodExpires when not zero.
+func WithBillingRetry(gracePeriodExpires time.Time) RenewalOption {
+	return func(r *datatypes.JWSRenewalInfoDecodedPayload) {
+		r.IsInBillingRetryPeriod = true
+		r.ExpirationIntent = datatypes.BillingError
+		if !gracePeriodExpires.IsZero() {
+			r.GracePeriodExpiresDate = gracePeriodExpires.UnixMilli()
+		}
+	}
+}
+
+// NewAppTransaction returns the app transaction of an app purchased now in the sandbox.
+func NewAppTransaction(bundleID string) datatypes.AppTransaction {
+	now := time.Now().UnixMilli()
+	return datatypes.AppTransaction{
+		ReceiptType:                datatypes.Sandbox,
+		BundleID:                   bundleID,
+		ApplicationVersion:         "1",
+		ReceiptCreationDate:        now,
+		OriginalPurchaseDate:       now,
+		OriginalApplicationVersion: "1",
+		DeviceVerification:         "dGVzdA==",
+		DeviceVerificationNonce:    "00000000-0000-0000-0000-000000000000",
+	}
+}
+
+// SignTransaction signs NewTransaction(opts...).
+func (ca *CA) SignTransaction(opts ...TransactionOption) (string, error) {
+	return ca.Sign(NewTransaction(opts...))
+}
+
+// SignRenewalInfo signs NewRenewalInfo(t, opts...).
+func (ca *CA) SignRenewalInfo(t datatypes.JWSTransactionDecodedPayload, opts ...RenewalOption) (string, error) {
+	return ca.Sign(NewRenewalInfo(t, opts...))
+}
+
+// SignAppTransaction signs the app transaction at.
+func (ca *CA) SignAppTransaction(at datatypes.AppTransaction) (string, error) {
+	return ca.Sign(at)
+}
+
+// SignNotification signs a version 2 notification about the transaction t and its renewal info,
+// which are signed into its data when not nil.
+func (ca *CA) SignNotification(typ notifications.NotificationType, subtype notifications.Subtype,
+	t *datatypes.JWSTransactionDecodedPayload, renewal *datatypes.JWSRenewalInfoDecodedPayload) (string, error) {
+	p := notifications.ResponseBodyV2DecodedPayload{
+		NotificationType: typ,
+		Subtype:          subtype,
+		Version:          "2.0",
+		SignedDate:       time.Now().UnixMilli(),
+		NotificationUUID: newUUID(),
+	}
+	var err error
+	if t != nil {
+		p.Data.BundleID = t.BundleID
+		p.Data.Environment = string(t.Environment)
+		if p.Data.SignedTransactionInfo, err = ca.Sign(t); err != nil {
+			return "", err
+		}
+	}
+	if renewal != nil {
+		p.Data.Environment = string(renewal.Environment)
+		if p.Data.SignedRenewalInfo, err = ca.Sign(renewal); err != nil {
+			return "", err
+		}
+	}
+	return ca.Sign(p)
+}
+
+// Must returns jws, it panics if err is not nil, e.g. Must(ca.SignTransaction()).
+func Must(jws string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return jws
+}
+
+// newID returns a random numeric identifier shaped like a production transaction ID.
+func newID() string {
+	n, _ := rand.Int(rand.Reader, big.NewInt(1e15))
+	return strconv.FormatInt(2e15+n.Int64(), 10)
+}
+
+func newUUID() string {
+	token := newToken()
+	return token[0:8] + "-" + token[8:12] + "-" + token[12:16] + "-" + token[16:20] + "-" + token[20:32]
+}
@@ -0,0 +1,108 @@
+package appstoretest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	notifications "github.com/gh73962/appleapis/appstore/notifications/v2"
+)
+
+func TestNewTransaction(t *testing.T) {
+	purchased := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		opts  []TransactionOption
+		check func(datatypes.JWSTransactionDecodedPayload) bool
+	}{
+		{
+			name: "first purchase",
+			opts: []TransactionOption{WithTransactionID("2000000000000001")},
+			check: func(p datatypes.JWSTransactionDecodedPayload) bool {
+				return p.TransactionID == "2000000000000001" && p.OriginalTransactionID == "2000000000000001"
+			},
+		},
+		{
+			name: "renewal",
+			opts: []TransactionOption{WithOriginalTransactionID("2000000000000000")},
+			check: func(p datatypes.JWSTransactionDecodedPayload) bool {
+				return p.OriginalTransactionID == "2000000000000000" && p.TransactionReason == "RENEWAL"
+			},
+		},
+		{
+			name: "purchase date keeps the period",
+			opts: []TransactionOption{WithPurchaseDate(purchased)},
+			check: func(p datatypes.JWSTransactionDecodedPayload) bool {
+				period := time.UnixMilli(p.ExpiresDate).Sub(time.UnixMilli(p.PurchaseDate))
+				return p.PurchaseDate == purchased.UnixMilli() && period >= 28*24*time.Hour && period <= 31*24*time.Hour
+			},
+		},
+		{
+			name: "consumable",
+			opts: []TransactionOption{WithType(datatypes.Consumable)},
+			check: func(p datatypes.JWSTransactionDecodedPayload) bool {
+				return p.ExpiresDate == 0 && p.SubscriptionGroupIdentifier == ""
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewTransaction(tt.opts...); !tt.check(got) {
+				t.Errorf("NewTransaction() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestCASignedFixtures(t *testing.T) {
+	ca, err := NewCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFunc := func(*jwtv5.Token) (any, error) { return ca.Leaf.PublicKey, nil }
+	verify := func(jws string) {
+		t.Helper()
+		if _, err := jwtv5.Parse(jws, keyFunc, jwtv5.WithValidMethods([]string{"ES256"}), jwtv5.WithoutClaimsValidation()); err != nil {
+			t.Errorf("Parse() error = %v", err)
+		}
+	}
+
+	txn := NewTransaction(WithProductID("com.example.app.yearly"), WithRevocation(time.Now(), 1))
+	signedTxn := Must(ca.Sign(txn))
+	verify(signedTxn)
+	decoded, err := appstoreapi.DecodeToJWSTransaction(signedTxn)
+	if err != nil || decoded.Payload != txn || len(decoded.Header.X5c) != 3 {
+		t.Errorf("DecodeToJWSTransaction() = %+v, %v", decoded, err)
+	}
+
+	renewal := NewRenewalInfo(txn, WithAutoRenewOff(datatypes.CanceledSubscription))
+	signedRenewal := Must(ca.SignRenewalInfo(txn, WithAutoRenewOff(datatypes.CanceledSubscription)))
+	verify(signedRenewal)
+	decodedRenewal, err := appstoreapi.DecodeToJWSRenewalInfo(signedRenewal)
+	if err != nil || decodedRenewal.Payload.IsAutoRenew() || decodedRenewal.Payload.RenewalDate != renewal.RenewalDate {
+		t.Errorf("DecodeToJWSRenewalInfo() = %+v, %v", decodedRenewal, err)
+	}
+
+	signedNotification := Must(ca.SignNotification(notifications.Refund, "", &txn, &renewal))
+	verify(signedNotification)
+	n, err := notifications.DecodeToJWSNotification(signedNotification)
+	if err != nil || n.Payload.NotificationType != notifications.Refund {
+		t.Fatalf("DecodeToJWSNotification() = %+v, %v", n, err)
+	}
+	verify(n.Payload.Data.SignedTransactionInfo)
+	if inner, err := appstoreapi.DecodeToJWSTransaction(n.Payload.Data.SignedTransactionInfo); err != nil || inner.Payload != txn {
+		t.Errorf("notification transaction = %+v, %v", inner, err)
+	}
+
+	signedApp := Must(ca.SignAppTransaction(NewAppTransaction("com.example.app")))
+	verify(signedApp)
+	_, payload, _, err := appstoreapi.DecodeSignedData(signedApp)
+	var app datatypes.AppTransaction
+	if err != nil || json.Unmarshal(payload, &app) != nil || app.BundleID != "com.example.app" {
+		t.Errorf("app transaction = %+v, %v", app, err)
+	}
+}
@@ -0,0 +1,412 @@
+package appstoretest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+)
+
+// ErrNoInteraction is returned by a replaying Recorder for a request the cassette has no response for.
+var ErrNoInteraction = errors.New("appstoretest: no recorded interaction")
+
+// Mode selects whether a Recorder records or replays exchanges.
+type Mode int
+
+const (
+	// ModeReplay serves the responses of the cassette, without network access.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests upstream and keeps the exchanges, written to the cassette by Save.
+	ModeRecord
+)
+
+// scrubbedBearer replaces the bearer token of recorded requests.
+const scrubbedBearer = "Bearer scrubbed"
+
+// Cassette is the file format of recorded exchanges.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// RecorderOption configures a Recorder.
+type RecorderOption func(*Recorder)
+
+// WithUpstream sends recorded requests through rt, by default a transport from appstoreapi.NewTransport.
+func WithUpstream(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.upstream = rt
+	}
+}
+
+// WithPseudonymizedIDs replaces transaction IDs in URLs, JSON bodies and the payloads of signed data with stable
+// pseudonyms derived from salt. Rewritten signed data keeps its header and signature, which no longer verify.
+// Replayed requests match with either the original or the pseudonymized IDs.
+func WithPseudonymizedIDs(salt string) RecorderOption {
+	return func(r *Recorder) {
+		r.salt = salt
+		r.pseudonymize = true
+	}
+}
+
+// WithLenientMatching matches replayed requests by method, path and query, ignoring the order of the query
+// parameters and the body, in any order and as often as needed. Interactions recorded several times for the same
+// request are replayed in the recorded order, the last one repeated once they are used up.
+// By default requests must match method, URL and JSON body, in the recorded order.
+func WithLenientMatching() RecorderOption {
+	return func(r *Recorder) {
+		r.lenient = true
+	}
+}
+
+// Recorder is an http.RoundTripper recording App Store Server API exchanges to a cassette file, or replaying them.
+//
+//	rec, err := appstoretest.NewRecorder("testdata/history.json", appstoretest.ModeReplay)
+//	s := appstoreapi.NewAppStoreService(ctx, appstoreapi.WithHTTPClient(rec.Client()), appstoreapi.WithSandbox())
+//
+// Bearer tokens are never recorded.
+type Recorder struct {
+	path         string
+	mode         Mode
+	upstream     http.RoundTripper
+	lenient      bool
+	pseudonymize bool
+	salt         string
+
+	mu       sync.Mutex
+	cassette Cassette
+	next     int    // next interaction to replay in strict mode
+	used     []bool // interactions replayed in lenient mode
+}
+
+// NewRecorder returns a Recorder for the cassette at path, which is loaded in ModeReplay.
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.upstream == nil {
+		r.upstream = appstoreapi.NewTransport(http.ProxyFromEnvironment)
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("appstoretest: cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Client returns an http.Client sending requests through r.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Save writes the recorded exchanges to the cassette file, it does nothing in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+// RoundTrip records or replays req, which is left unmodified as http.RoundTripper requires.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, out, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, out, body)
+}
+
+// readRequestBody consumes and closes the body of req, and returns it with a clone of req sending it again.
+func readRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, out, nil
+}
+
+// record sends out, the copy of req with its body, upstream.
+func (r *Recorder) record(req, out *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.upstream.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := make(http.Header)
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	if req.Header.Get("Authorization") != "" {
+		header.Set("Authorization", scrubbedBearer)
+	}
+	respHeader := resp.Header.Clone()
+	respHeader.Del("Set-Cookie")
+
+	in := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    r.scrubURL(req.URL.String()),
+			Header: header,
+			Body:   r.scrubBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     respHeader,
+			Body:       r.scrubBody(respBody),
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	candidates := []RecordedRequest{{Method: req.Method, URL: req.URL.String(), Body: string(body)}}
+	if r.pseudonymize {
+		candidates = append(candidates, RecordedRequest{
+			Method: req.Method,
+			URL:    r.scrubURL(req.URL.String()),
+			Body:   r.scrubBody(body),
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	in, ok := r.match(candidates)
+	if !ok {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, req.URL.Path)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(in.Response.StatusCode) + " " + http.StatusText(in.Response.StatusCode),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the interaction recorded for one of candidates, r.mu is held.
+func (r *Recorder) match(candidates []RecordedRequest) (Interaction, bool) {
+	if !r.lenient {
+		if r.next >= len(r.cassette.Interactions) {
+			return Interaction{}, false
+		}
+		in := r.cassette.Interactions[r.next]
+		for _, c := range candidates {
+			if c.Method == in.Request.Method && c.URL == in.Request.URL && sameBody(c.Body, in.Request.Body) {
+				r.next++
+				return in, true
+			}
+		}
+		return Interaction{}, false
+	}
+
+	last := -1
+	for i, in := range r.cassette.Interactions {
+		for _, c := range candidates {
+			if c.Method == in.Request.Method && sameURL(c.URL, in.Request.URL) {
+				if !r.used[i] {
+					r.used[i] = true
+					return in, true
+				}
+				last = i
+			}
+		}
+	}
+	if last < 0 {
+		return Interaction{}, false
+	}
+	return r.cassette.Interactions[last], true
+}
+
+// sameURL compares the paths of a and b and their query parameters, in any order.
+func sameURL(a, b string) bool {
+	pathA, queryA, _ := strings.Cut(a, "?")
+	pathB, queryB, _ := strings.Cut(b, "?")
+	if pathA != pathB {
+		return false
+	}
+	if queryA == queryB {
+		return true
+	}
+	va, errA := url.ParseQuery(queryA)
+	vb, errB := url.ParseQuery(queryB)
+	return errA == nil && errB == nil && reflect.DeepEqual(va, vb)
+}
+
+// sameBody compares JSON bodies by value, other bodies byte by byte.
+func sameBody(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// pseudonymizedKeys are the JSON fields holding transaction IDs.
+var pseudonymizedKeys = map[string]bool{
+	"transactionId":         true,
+	"originalTransactionId": true,
+	"webOrderLineItemId":    true,
+}
+
+// pseudonym returns the stable pseudonym of id, a 16 digit number starting with 9.
+func (r *Recorder) pseudonym(id string) string {
+	sum := sha256.Sum256([]byte(r.salt + id))
+	return strconv.FormatUint(9e15+binary.BigEndian.Uint64(sum[:8])%1e15, 10)
+}
+
+// scrubURL pseudonymizes the numeric path segments of u.
+func (r *Recorder) scrubURL(u string) string {
+	if !r.pseudonymize {
+		return u
+	}
+	path, query, hasQuery := strings.Cut(u, "?")
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if isNumeric(s) {
+			segments[i] = r.pseudonym(s)
+		}
+	}
+	path = strings.Join(segments, "/")
+	if hasQuery {
+		return path + "?" + query
+	}
+	return path
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// scrubBody pseudonymizes the transaction IDs of a JSON body, other bodies are kept.
+func (r *Recorder) scrubBody(body []byte) string {
+	if !r.pseudonymize || len(body) == 0 {
+		return string(body)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if dec.Decode(&v) != nil {
+		return string(body)
+	}
+	data, err := json.Marshal(r.scrubValue("", v))
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+func (r *Recorder) scrubValue(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = r.scrubValue(k, e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = r.scrubValue(key, e)
+		}
+	case string:
+		if pseudonymizedKeys[key] {
+			return r.pseudonym(v)
+		}
+		return r.scrubJWS(v)
+	}
+	return v
+}
+
+// scrubJWS pseudonymizes the payload of s when it is signed data.
+func (r *Recorder) scrubJWS(s string) string {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return s
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(payload) == 0 || payload[0] != '{' {
+		return s
+	}
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(r.scrubBody(payload)))
+	return strings.Join(parts, ".")
+}
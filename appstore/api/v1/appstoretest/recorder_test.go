@@ -0,0 +1,201 @@
+package appstoretest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestRecorder(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddTransaction(NewTransaction(WithTransactionID("2000000000000001"), WithProductID("monthly")))
+	cassette := filepath.Join(t.TempDir(), "cassettes", "transactions.json")
+	ctx := context.Background()
+
+	rec, err := NewRecorder(cassette, ModeRecord, WithUpstream(srv.Client().Transport), WithPseudonymizedIDs("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := appstoreapi.NewAppStoreService(ctx, appstoreapi.WithHTTPClient(rec.Client()), appstoreapi.WithBaseURL(srv.BaseURL()))
+	if _, err := s.TransactionInfo(ctx, "secret-bearer", "2000000000000001"); err != nil {
+		t.Fatalf("TransactionInfo() error = %v", err)
+	}
+	if _, err := s.TransactionHistory(ctx, "secret-bearer", "2000000000000001"); err != nil {
+		t.Fatalf("TransactionHistory() error = %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-bearer") || strings.Contains(string(data), "2000000000000001") {
+		t.Errorf("cassette leaks the bearer or transaction ID:\n%s", data)
+	}
+	srv.Close()
+
+	tests := []struct {
+		name    string
+		opts    []RecorderOption
+		calls   func(s *appstoreapi.Service) error
+		wantErr error
+	}{
+		{
+			name: "strict in order",
+			opts: []RecorderOption{WithPseudonymizedIDs("salt")},
+			calls: func(s *appstoreapi.Service) error {
+				info, err := s.TransactionInfo(ctx, "bearer", "2000000000000001")
+				if err != nil {
+					return err
+				}
+				if info.Payload.ProductID != "monthly" {
+					t.Errorf("TransactionInfo() = %+v", info.Payload)
+				}
+				_, err = s.TransactionHistory(ctx, "bearer", "2000000000000001")
+				return err
+			},
+		},
+		{
+			name: "strict out of order",
+			opts: []RecorderOption{WithPseudonymizedIDs("salt")},
+			calls: func(s *appstoreapi.Service) error {
+				_, err := s.TransactionHistory(ctx, "bearer", "2000000000000001")
+				return err
+			},
+			wantErr: ErrNoInteraction,
+		},
+		{
+			name: "lenient by pseudonym",
+			opts: []RecorderOption{WithPseudonymizedIDs("salt"), WithLenientMatching()},
+			calls: func(s *appstoreapi.Service) error {
+				for i := 0; i < 2; i++ {
+					history, err := s.TransactionHistory(ctx, "bearer", "2000000000000001")
+					if err != nil {
+						return err
+					}
+					tx, err := appstoreapi.DecodeToJWSTransaction(history.SignedTransactions[0])
+					if err != nil {
+						return err
+					}
+					if !strings.HasPrefix(tx.Payload.TransactionID, "9") {
+						t.Errorf("replayed TransactionID = %s, want a pseudonym", tx.Payload.TransactionID)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "unknown request",
+			opts: []RecorderOption{WithLenientMatching()},
+			calls: func(s *appstoreapi.Service) error {
+				_, err := s.AllSubscriptionStatuses(ctx, "bearer", "2000000000000001", datatypes.Active)
+				return err
+			},
+			wantErr: ErrNoInteraction,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := NewRecorder(cassette, ModeReplay, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := appstoreapi.NewAppStoreService(ctx, appstoreapi.WithHTTPClient(rec.Client()), appstoreapi.WithBaseURL(srv.BaseURL()))
+			if err := tt.calls(s); !errors.Is(err, tt.wantErr) {
+				t.Errorf("calls error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecorderPaginatedReplay(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.PageSize = 1
+	refunded := WithRevocation(time.Unix(1700000000, 0), 0)
+	srv.AddTransaction(NewTransaction(WithTransactionID("1"), WithOriginalTransactionID("1"), refunded))
+	srv.AddTransaction(NewTransaction(WithTransactionID("2"), WithOriginalTransactionID("1"), refunded))
+	cassette := filepath.Join(t.TempDir(), "refunds.json")
+
+	type page struct {
+		Revision           string   `json:"revision"`
+		SignedTransactions []string `json:"signedTransactions"`
+	}
+	get := func(client *http.Client, revision string) page {
+		t.Helper()
+		u := srv.BaseURL() + "refund/lookup/1"
+		if revision != "" {
+			u += "?revision=" + revision
+		}
+		req, _ := http.NewRequest(http.MethodGet, u, nil)
+		req.Header.Set("Authorization", "Bearer bearer")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", u, err)
+		}
+		defer resp.Body.Close()
+		var p page
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil || len(p.SignedTransactions) != 1 {
+			t.Fatalf("GET %s = %+v, %v", u, p, err)
+		}
+		return p
+	}
+
+	rec, err := NewRecorder(cassette, ModeRecord, WithUpstream(srv.Client().Transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := get(rec.Client(), "")
+	second := get(rec.Client(), first.Revision)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if first.SignedTransactions[0] == second.SignedTransactions[0] {
+		t.Fatal("recorded the same page twice")
+	}
+
+	rec, err = NewRecorder(cassette, ModeReplay, WithLenientMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(rec.Client(), first.Revision); got.SignedTransactions[0] != second.SignedTransactions[0] {
+		t.Error("replayed page one for the revision of page two")
+	}
+	if got := get(rec.Client(), ""); got.SignedTransactions[0] != first.SignedTransactions[0] {
+		t.Error("replayed page two for page one")
+	}
+}
+
+func TestRecorderKeepsRequest(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	rec, err := NewRecorder(filepath.Join(t.TempDir(), "consumption.json"), ModeRecord, WithUpstream(srv.Client().Transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := io.NopCloser(bytes.NewReader([]byte(`{}`)))
+	req, _ := http.NewRequest(http.MethodPut, srv.BaseURL()+"transactions/consumption/1", body)
+	req.Header.Set("Authorization", "Bearer bearer")
+	resp, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Body != body {
+		t.Error("RoundTrip() replaced the body of the request")
+	}
+}
@@ -0,0 +1,475 @@
+// Package appstoretest provides a local App Store Server API served by httptest, to run Service end-to-end
+// without network access. State is kept in memory and responses are signed by a throwaway CA.
+// The same CA mints signed fixtures for tests of verification and business logic, see NewTransaction.
+//
+//	srv := appstoretest.NewServer()
+//	defer srv.Close()
+//	srv.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1", ProductID: "monthly"})
+//	srv.InjectFault(appstoreapi.EndpointTransactionInfo, appstoretest.Fault{Status: http.StatusTooManyRequests, Times: 1})
+//	s := appstoreapi.NewAppStoreService(ctx, srv.Options()...)
+package appstoretest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	"github.com/gh73962/appleapis/appstore/api/v1/internal/store"
+)
+
+const basePath = "/inApps/v1/"
+
+// DefaultPageSize is the number of transactions or notifications answered per page.
+const DefaultPageSize = 20
+
+// Fault replaces or degrades the responses of an endpoint, see Server.InjectFault.
+type Fault struct {
+	Status     int           // answered instead of the endpoint when not 0
+	ErrorCode  int64         // sent in the body of Status, e.g. datatypes.ErrorCodeRateLimitExceeded
+	RetryAfter time.Duration // sent in the Retry-After header of Status
+	Delay      time.Duration // waited before answering
+	Truncate   bool          // cuts the response body in half, the client reads an unexpected EOF
+	Times      int           // number of requests the fault applies to, 0 for every request until ClearFaults
+}
+
+// Server emulates the App Store Server API under BaseURL.
+type Server struct {
+	*httptest.Server
+	CA          *CA
+	BundleID    string
+	Environment datatypes.Environment
+	PageSize    int
+
+	store store.Store
+
+	mu     sync.Mutex
+	faults map[appstoreapi.Endpoint][]*Fault
+}
+
+// NewServer starts a Server, the caller should call Close when finished.
+func NewServer() *Server {
+	ca, err := NewCA()
+	if err != nil {
+		panic(fmt.Sprintf("appstoretest: generating CA: %v", err))
+	}
+	s := &Server{
+		CA:          ca,
+		BundleID:    "com.example.app",
+		Environment: datatypes.LocalTesting,
+		PageSize:    DefaultPageSize,
+		faults:      make(map[appstoreapi.Endpoint][]*Fault),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL returns the base URL of the emulated API.
+func (s *Server) BaseURL() string {
+	return s.URL + basePath
+}
+
+// Options configure a Service to call s.
+func (s *Server) Options() []appstoreapi.Option {
+	return []appstoreapi.Option{
+		appstoreapi.WithHTTPClient(s.Client()),
+		appstoreapi.WithBaseURL(s.BaseURL()),
+		appstoreapi.WithEnvironment(s.Environment),
+	}
+}
+
+// AddTransaction stores t, it is answered by transactions and in the history of its original transaction.
+func (s *Server) AddTransaction(t datatypes.JWSTransactionDecodedPayload) {
+	s.store.AddTransaction(s.withDefaults(t))
+}
+
+// withDefaults sets the bundle ID and environment of t to those of s when empty.
+func (s *Server) withDefaults(t datatypes.JWSTransactionDecodedPayload) datatypes.JWSTransactionDecodedPayload {
+	if t.BundleID == "" {
+		t.BundleID = s.BundleID
+	}
+	if t.Environment == "" {
+		t.Environment = s.Environment
+	}
+	return t
+}
+
+// AddOrder makes lookup answer the stored transactions with transactionIDs for orderID.
+func (s *Server) AddOrder(orderID string, transactionIDs ...string) {
+	s.store.AddOrder(orderID, transactionIDs...)
+}
+
+// SetSubscriptionStatus sets the status of the subscription group groupID, its latest transaction t is stored too.
+func (s *Server) SetSubscriptionStatus(groupID string, status datatypes.SubscriptionStatus,
+	t datatypes.JWSTransactionDecodedPayload, renewal datatypes.JWSRenewalInfoDecodedPayload) {
+	s.store.SetSubscriptionStatus(groupID, status, s.withDefaults(t), renewal)
+}
+
+// AddNotification appends a notification with payload, signed when answered, to the notification history.
+func (s *Server) AddNotification(payload any, attempts ...datatypes.SendAttemptItem) {
+	s.store.AddNotification(store.Notification{Payload: payload, Attempts: attempts})
+}
+
+// Consumption returns the last consumption information sent for transactionID.
+func (s *Server) Consumption(transactionID string) (datatypes.ConsumptionRequest, bool) {
+	return s.store.Consumption(transactionID)
+}
+
+// InjectFault applies f to the requests to endpoint, or to every endpoint when endpoint is empty.
+// Faults of an endpoint apply in the order they were injected.
+func (s *Server) InjectFault(endpoint appstoreapi.Endpoint, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], &f)
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[appstoreapi.Endpoint][]*Fault)
+}
+
+// Calls returns the number of requests made to endpoint, faulted ones included.
+func (s *Server) Calls(endpoint appstoreapi.Endpoint) int {
+	return s.store.Calls(endpoint)
+}
+
+// fault counts a request to endpoint and returns the fault it gets.
+func (s *Server) fault(endpoint appstoreapi.Endpoint) Fault {
+	s.store.Count(endpoint)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range []appstoreapi.Endpoint{endpoint, ""} {
+		faults := s.faults[e]
+		if len(faults) == 0 {
+			continue
+		}
+		f := faults[0]
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults[e] = faults[1:]
+			}
+		}
+		return *f
+	}
+	return Fault{}
+}
+
+// route returns the endpoint addressed by method and path, relative to basePath, and its path parameter.
+func route(method string, path []string) (appstoreapi.Endpoint, string, bool) {
+	switch {
+	case method == http.MethodGet && len(path) == 2 && path[0] == "transactions":
+		return appstoreapi.EndpointTransactionInfo, path[1], true
+	case method == http.MethodPut && len(path) == 3 && path[0] == "transactions" && path[1] == "consumption":
+		return appstoreapi.EndpointSendConsumptionInfo, path[2], true
+	case method == http.MethodGet && len(path) == 2 && path[0] == "history":
+		return appstoreapi.EndpointTransactionHistory, path[1], true
+	case method == http.MethodGet && len(path) == 2 && path[0] == "subscriptions":
+		return appstoreapi.EndpointAllSubscriptionStatuses, path[1], true
+	case method == http.MethodGet && len(path) == 2 && path[0] == "lookup":
+		return appstoreapi.EndpointLookUpOrderID, path[1], true
+	case method == http.MethodGet && len(path) == 3 && path[0] == "refund" && path[1] == "lookup":
+		return appstoreapi.EndpointRefundHistory, path[2], true
+	case method == http.MethodPost && len(path) == 2 && path[0] == "notifications" && path[1] == "history":
+		return appstoreapi.EndpointNotificationHistory, "", true
+	case method == http.MethodPost && len(path) == 2 && path[0] == "notifications" && path[1] == "test":
+		return appstoreapi.EndpointTestNotification, "", true
+	case method == http.MethodGet && len(path) == 3 && path[0] == "notifications" && path[1] == "test":
+		return appstoreapi.EndpointGetTestNotificationStatus, path[2], true
+	}
+	return "", "", false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, basePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	endpoint, param, ok := route(r.Method, strings.Split(path, "/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); !ok || bearer == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	f := s.fault(endpoint)
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	var (
+		status int
+		body   any
+	)
+	if f.Status != 0 {
+		status = f.Status
+		if f.ErrorCode != 0 {
+			body = datatypes.ErrorResponse{ErrorCode: f.ErrorCode}
+		}
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+		}
+	} else {
+		status, body = s.handle(endpoint, param, r)
+	}
+	write(w, status, body, f.Truncate)
+}
+
+func write(w http.ResponseWriter, status int, body any, truncate bool) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			status, data = http.StatusInternalServerError, nil
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	if truncate && len(data) > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		data = data[:len(data)/2]
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// apiError returns the response of the API for the error code.
+func apiError(code int64) (int, any) {
+	return int(code / 10000), datatypes.ErrorResponse{ErrorCode: code}
+}
+
+func (s *Server) handle(endpoint appstoreapi.Endpoint, param string, r *http.Request) (int, any) {
+	var (
+		body any
+		err  error
+	)
+	switch endpoint {
+	case appstoreapi.EndpointTransactionInfo:
+		t, ok := s.store.Transaction(param)
+		if !ok {
+			return apiError(datatypes.ErrorCodeTransactionIDNotFound)
+		}
+		var rsp datatypes.TransactionInfoResponse
+		rsp.SignedTransactionInfo, err = s.CA.Sign(t)
+		body = rsp
+	case appstoreapi.EndpointSendConsumptionInfo:
+		return s.sendConsumption(param, r)
+	case appstoreapi.EndpointTransactionHistory:
+		return s.transactionHistory(param, r)
+	case appstoreapi.EndpointAllSubscriptionStatuses:
+		return s.subscriptionStatuses(param, r)
+	case appstoreapi.EndpointLookUpOrderID:
+		return s.lookUpOrderID(param)
+	case appstoreapi.EndpointRefundHistory:
+		return s.refundHistory(param, r)
+	case appstoreapi.EndpointNotificationHistory:
+		return s.notificationHistory(r)
+	case appstoreapi.EndpointTestNotification:
+		token := newToken()
+		s.store.AddTestNotification(token, store.Notification{
+			Payload: map[string]any{
+				"notificationType": "TEST",
+				"notificationUUID": token,
+				"version":          "2.0",
+				"signedDate":       time.Now().UnixMilli(),
+				"data":             map[string]any{"bundleId": s.BundleID, "environment": s.Environment},
+			},
+			Attempts: []datatypes.SendAttemptItem{{AttemptDate: time.Now().UnixMilli(), SendAttemptResult: "SUCCESS"}},
+		})
+		body = datatypes.SendTestNotificationResponse{TestNotificationToken: token}
+	case appstoreapi.EndpointGetTestNotificationStatus:
+		n, ok := s.store.TestNotification(param)
+		if !ok {
+			return apiError(datatypes.ErrorCodeTestNotificationNotFound)
+		}
+		body, err = s.notificationItem(n)
+	}
+	if err != nil {
+		return apiError(datatypes.ErrorCodeGeneralInternal)
+	}
+	return http.StatusOK, body
+}
+
+func (s *Server) sendConsumption(transactionID string, r *http.Request) (int, any) {
+	if _, ok := s.store.Transaction(transactionID); !ok {
+		return apiError(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+	var cr datatypes.ConsumptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil || cr.Validate() != nil {
+		return apiError(datatypes.ErrorCodeGeneralBadRequest)
+	}
+	s.store.SendConsumption(transactionID, cr)
+	return http.StatusAccepted, nil
+}
+
+// page returns the bounds of the page of n items starting at token, the token of the next page is end.
+func (s *Server) page(token string, n int) (start, end int, ok bool) {
+	size := s.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return store.Page(token, n, size)
+}
+
+// signedPage signs the page of history starting at revision, code is the error code of a failure.
+func (s *Server) signedPage(history []datatypes.JWSTransactionDecodedPayload, revision string) (signed []string, next string, hasMore bool, code int64) {
+	start, end, ok := s.page(revision, len(history))
+	if !ok {
+		return nil, "", false, datatypes.ErrorCodeInvalidRequestRevision
+	}
+	for _, t := range history[start:end] {
+		jws, err := s.CA.Sign(t)
+		if err != nil {
+			return nil, "", false, datatypes.ErrorCodeGeneralInternal
+		}
+		signed = append(signed, jws)
+	}
+	return signed, strconv.Itoa(end), end < len(history), 0
+}
+
+func (s *Server) transactionHistory(transactionID string, r *http.Request) (int, any) {
+	history, ok := s.store.History(transactionID, nil)
+	if !ok {
+		return apiError(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+	signed, revision, hasMore, code := s.signedPage(history, r.URL.Query().Get("revision"))
+	if code != 0 {
+		return apiError(code)
+	}
+	return http.StatusOK, datatypes.HistoryResponse{
+		Revision:           revision,
+		BundleID:           s.BundleID,
+		Environment:        s.Environment,
+		HasMore:            hasMore,
+		SignedTransactions: signed,
+	}
+}
+
+func (s *Server) refundHistory(transactionID string, r *http.Request) (int, any) {
+	refunded, ok := s.store.History(transactionID, store.Refunded)
+	if !ok {
+		return apiError(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+	signed, revision, hasMore, code := s.signedPage(refunded, r.URL.Query().Get("revision"))
+	if code != 0 {
+		return apiError(code)
+	}
+	return http.StatusOK, datatypes.RefundHistoryResponse{
+		Revision:           revision,
+		HasMore:            hasMore,
+		SignedTransactions: signed,
+	}
+}
+
+func (s *Server) subscriptionStatuses(transactionID string, r *http.Request) (int, any) {
+	var statuses []datatypes.SubscriptionStatus
+	for _, v := range r.URL.Query()["status"] {
+		n, _ := strconv.Atoi(v)
+		statuses = append(statuses, datatypes.SubscriptionStatus(n))
+	}
+	groups, ok := s.store.Statuses(transactionID, statuses...)
+	if !ok {
+		return apiError(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+
+	rsp := datatypes.StatusResponse{BundleID: s.BundleID, Environment: string(s.Environment)}
+	for _, g := range groups {
+		signedTransaction, err := s.CA.Sign(g.Transaction)
+		if err != nil {
+			return apiError(datatypes.ErrorCodeGeneralInternal)
+		}
+		signedRenewal, err := s.CA.Sign(g.Renewal)
+		if err != nil {
+			return apiError(datatypes.ErrorCodeGeneralInternal)
+		}
+		rsp.Data = append(rsp.Data, datatypes.SubscriptionGroupIdentifierItem{
+			SubscriptionGroupIdentifier: g.ID,
+			LastTransactions: []datatypes.LastTransactionsItem{{
+				OriginalTransactionID: g.Transaction.OriginalTransactionID,
+				Status:                g.Status,
+				SignedRenewalInfo:     signedRenewal,
+				SignedTransactionInfo: signedTransaction,
+			}},
+		})
+	}
+	return http.StatusOK, rsp
+}
+
+func (s *Server) lookUpOrderID(orderID string) (int, any) {
+	order, ok := s.store.Order(orderID)
+	if !ok {
+		return http.StatusOK, datatypes.OrderLookupResponse{Status: 1}
+	}
+	var rsp datatypes.OrderLookupResponse
+	for _, t := range order {
+		jws, err := s.CA.Sign(t)
+		if err != nil {
+			return apiError(datatypes.ErrorCodeGeneralInternal)
+		}
+		rsp.SignedTransactions = append(rsp.SignedTransactions, jws)
+	}
+	return http.StatusOK, rsp
+}
+
+func (s *Server) notificationHistory(r *http.Request) (int, any) {
+	var req datatypes.NotificationHistoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apiError(datatypes.ErrorCodeGeneralBadRequest)
+	}
+
+	matched := s.store.Notifications(&req)
+	start, end, ok := s.page(r.URL.Query().Get("paginationToken"), len(matched))
+	if !ok {
+		return apiError(datatypes.ErrorCodeInvalidPaginationToken)
+	}
+
+	var rsp datatypes.NotificationHistoryResponse
+	for _, n := range matched[start:end] {
+		item, err := s.notificationItem(n)
+		if err != nil {
+			return apiError(datatypes.ErrorCodeGeneralInternal)
+		}
+		rsp.NotificationHistory = append(rsp.NotificationHistory, item)
+	}
+	if end < len(matched) {
+		rsp.HasMore = true
+		rsp.PaginationToken = strconv.Itoa(end)
+	}
+	return http.StatusOK, rsp
+}
+
+func (s *Server) notificationItem(n store.Notification) (datatypes.NotificationHistoryResponseItem, error) {
+	signed := n.SignedPayload
+	if signed == "" {
+		var err error
+		if signed, err = s.CA.Sign(n.Payload); err != nil {
+			return datatypes.NotificationHistoryResponseItem{}, err
+		}
+	}
+	return datatypes.NotificationHistoryResponseItem{SignedPayload: signed, SendAttempts: n.Attempts}, nil
+}
+
+func newToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
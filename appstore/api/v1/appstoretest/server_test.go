@@ -0,0 +1,234 @@
+package appstoretest
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	jwtv5 "github.com/golang-jwt/jwt/v5"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// noBackoff retries without pausing.
+type noBackoff struct{}
+
+func (noBackoff) Pause() time.Duration { return 0 }
+
+func newService(srv *Server, options ...appstoreapi.Option) *appstoreapi.Service {
+	options = append(srv.Options(), options...)
+	s := appstoreapi.NewAppStoreService(context.Background(), options...)
+	s.NewBackoff = func() appstoreapi.Backoff { return noBackoff{} }
+	return s
+}
+
+func TestServerSignsWithChain(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1", ProductID: "monthly"})
+
+	info, err := newService(srv).TransactionInfo(context.Background(), "bearer", "1")
+	if err != nil {
+		t.Fatalf("TransactionInfo() error = %v", err)
+	}
+	if info.Payload.ProductID != "monthly" || info.Payload.Environment != datatypes.LocalTesting {
+		t.Errorf("TransactionInfo() = %+v", info.Payload)
+	}
+
+	var chain []*x509.Certificate
+	for _, raw := range info.Header.X5c {
+		der, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("x5c has %d certificates, want 3", len(chain))
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(chain[1])
+	if _, err := chain[0].Verify(x509.VerifyOptions{Roots: srv.CA.Roots(), Intermediates: intermediates}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+
+	signed, err := srv.CA.Sign(info.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jwtv5.Parse(signed, func(*jwtv5.Token) (any, error) { return chain[0].PublicKey, nil },
+		jwtv5.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+}
+
+func TestServerRevisions(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		srv.AddTransaction(datatypes.JWSTransactionDecodedPayload{
+			TransactionID:         strconv.Itoa(i),
+			OriginalTransactionID: "1",
+			PurchaseDate:          int64(i),
+			RevocationDate:        int64(i),
+		})
+	}
+	s := newService(srv)
+
+	var (
+		revision string
+		pages    int
+		refunds  int
+	)
+	for {
+		rsp, err := s.RefundHistory(context.Background(), "bearer", "1", revision)
+		if err != nil {
+			t.Fatalf("RefundHistory() error = %v", err)
+		}
+		pages++
+		refunds += len(rsp.SignedTransactions)
+		if !rsp.HasMore {
+			break
+		}
+		revision = rsp.Revision
+	}
+	if pages != 3 || refunds != 5 {
+		t.Errorf("RefundHistory() pages = %d, refunds = %d, want 3, 5", pages, refunds)
+	}
+
+	_, err := s.RefundHistory(context.Background(), "bearer", "1", "bogus")
+	var errResp *datatypes.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.ErrorCode != datatypes.ErrorCodeInvalidRequestRevision {
+		t.Errorf("RefundHistory(bogus) error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		srv.AddNotification(map[string]any{"notificationType": "DID_RENEW"},
+			datatypes.SendAttemptItem{AttemptDate: int64(i), SendAttemptResult: "SUCCESS"})
+	}
+	history, err := s.NotificationHistory(context.Background(), "bearer", "", &datatypes.NotificationHistoryRequest{})
+	if err != nil || len(history.NotificationHistory) != 2 || !history.HasMore {
+		t.Fatalf("NotificationHistory() = %+v, %v", history, err)
+	}
+	history, err = s.NotificationHistory(context.Background(), "bearer", history.PaginationToken, &datatypes.NotificationHistoryRequest{})
+	if err != nil || len(history.NotificationHistory) != 1 || history.HasMore {
+		t.Errorf("NotificationHistory() second page = %+v, %v", history, err)
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.SetSubscriptionStatus("group", datatypes.Active,
+		datatypes.JWSTransactionDecodedPayload{TransactionID: "10"},
+		datatypes.JWSRenewalInfoDecodedPayload{AutoRenewProductID: "yearly"})
+	srv.AddOrder("ORDER1", "10")
+	s := newService(srv)
+	ctx := context.Background()
+
+	status, err := s.AllSubscriptionStatuses(ctx, "bearer", "10", datatypes.Active)
+	if err != nil || len(status.Data) != 1 {
+		t.Fatalf("AllSubscriptionStatuses() = %+v, %v", status, err)
+	}
+	renewal, err := appstoreapi.DecodeToJWSRenewalInfo(status.Data[0].LastTransactions[0].SignedRenewalInfo)
+	if err != nil || renewal.Payload.AutoRenewProductID != "yearly" {
+		t.Errorf("AllSubscriptionStatuses() renewal = %+v, %v", renewal, err)
+	}
+
+	if order, err := s.LookUpOrderID(ctx, "bearer", "ORDER1"); err != nil || !order.IsValid() || len(order.SignedTransactions) != 1 {
+		t.Errorf("LookUpOrderID() = %+v, %v", order, err)
+	}
+	if history, err := s.TransactionHistory(ctx, "bearer", "10"); err != nil || len(history.SignedTransactions) != 1 {
+		t.Errorf("TransactionHistory() = %+v, %v", history, err)
+	}
+
+	if err := s.SendConsumptionInformation(ctx, "bearer", "10", &datatypes.ConsumptionRequest{CustomerConsented: true, PlayTime: 2}); err != nil {
+		t.Errorf("SendConsumptionInformation() error = %v", err)
+	}
+	if cr, ok := srv.Consumption("10"); !ok || cr.PlayTime != 2 {
+		t.Errorf("Consumption() = %+v, %v", cr, ok)
+	}
+
+	test, err := s.TestNotification(ctx, "bearer")
+	if err != nil {
+		t.Fatalf("TestNotification() error = %v", err)
+	}
+	if item, err := s.GetTestNotificationStatus(ctx, "bearer", test.TestNotificationToken); err != nil || item.SignedPayload == "" {
+		t.Errorf("GetTestNotificationStatus() = %+v, %v", item, err)
+	}
+
+	_, err = s.TransactionInfo(ctx, "bearer", "404")
+	var errResp *datatypes.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.ErrorCode != datatypes.ErrorCodeTransactionIDNotFound {
+		t.Errorf("TransactionInfo(unknown) error = %v", err)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		fault     Fault
+		options   []appstoreapi.CallOption
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "rate limited then recovered",
+			fault:     Fault{Status: http.StatusTooManyRequests, ErrorCode: datatypes.ErrorCodeRateLimitExceeded, Times: 2},
+			wantCalls: 3,
+		},
+		{
+			name:      "truncated body then recovered",
+			fault:     Fault{Truncate: true, Times: 1},
+			wantCalls: 2,
+		},
+		{
+			name:      "persistent truncated body",
+			fault:     Fault{Truncate: true},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:      "persistent 5xx",
+			fault:     Fault{Status: http.StatusInternalServerError},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:      "slow response",
+			fault:     Fault{Delay: time.Second},
+			options:   []appstoreapi.CallOption{appstoreapi.CallTimeout(50 * time.Millisecond)},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			srv.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1"})
+			srv.InjectFault(appstoreapi.EndpointTransactionInfo, tt.fault)
+			s := newService(srv, appstoreapi.WithRetryPolicy(&appstoreapi.DefaultRetryPolicy{MaxAttempts: 3}))
+
+			_, err := s.TransactionInfo(context.Background(), "bearer", "1", tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TransactionInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := srv.Calls(appstoreapi.EndpointTransactionInfo); got != tt.wantCalls {
+				t.Errorf("Calls() = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package appstoreapi
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// DefaultBatchConcurrency is the number of calls a batch runs at once unless set with BatchConcurrency.
+const DefaultBatchConcurrency = 8
+
+// BatchOption configures a batch call.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	concurrency int
+	ordered     bool
+	callOpts    []CallOption
+}
+
+// BatchConcurrency runs at most n calls of the batch at once, rate limits set with WithRateLimit still apply.
+func BatchConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// BatchOrdered streams results in the order of the IDs instead of as they complete.
+func BatchOrdered() BatchOption {
+	return func(o *batchOptions) {
+		o.ordered = true
+	}
+}
+
+// BatchCallOptions applies opts to every call of the batch.
+func BatchCallOptions(opts ...CallOption) BatchOption {
+	return func(o *batchOptions) {
+		o.callOpts = append(o.callOpts, opts...)
+	}
+}
+
+// BatchResult is the outcome of the call for one ID of a batch.
+type BatchResult[T any] struct {
+	Index int // of the ID in the input
+	ID    string
+	Value T
+	Err   error
+}
+
+// BatchFailure is a failed call of a batch.
+type BatchFailure struct {
+	Index int
+	ID    string
+	Err   error
+}
+
+// BatchSummary describes a finished batch, Failures are in input order. Err is the context error
+// when ctx was done before the batch finished, the IDs not read yet were not called.
+type BatchSummary struct {
+	Total     int
+	Succeeded int
+	Failures  []BatchFailure
+	Err       error
+}
+
+// Batch streams the results of a batch call, Results must be drained or Wait called to release its workers.
+type Batch[T any] struct {
+	results chan BatchResult[T]
+	summary BatchSummary
+}
+
+// Results returns the results of the batch, closed once every started call returned.
+func (b *Batch[T]) Results() <-chan BatchResult[T] {
+	return b.results
+}
+
+// Wait discards the results not read yet and returns the summary of the batch once it finished.
+func (b *Batch[T]) Wait() BatchSummary {
+	for range b.results {
+	}
+	return b.summary
+}
+
+// BatchIDs returns a closed channel of ids, to pass a slice to the batch methods.
+func BatchIDs(ids []string) <-chan string {
+	ch := make(chan string, len(ids))
+	for _, id := range ids {
+		ch <- id
+	}
+	close(ch)
+	return ch
+}
+
+// BatchTransactionInfo calls TransactionInfo for every ID read from ids until it's closed or ctx is done.
+func (s *Service) BatchTransactionInfo(ctx context.Context, bearer string, ids <-chan string,
+	opts ...BatchOption) *Batch[*datatypes.JWSTransaction] {
+	o := newBatchOptions(opts)
+	return runBatch(ctx, ids, o, func(ctx context.Context, id string) (*datatypes.JWSTransaction, error) {
+		return s.TransactionInfo(ctx, bearer, id, o.callOpts...)
+	})
+}
+
+// BatchAllSubscriptionStatuses calls AllSubscriptionStatuses for every ID read from ids until it's closed or ctx is done.
+func (s *Service) BatchAllSubscriptionStatuses(ctx context.Context, bearer string, ids <-chan string,
+	status datatypes.SubscriptionStatus, opts ...BatchOption) *Batch[*datatypes.StatusResponse] {
+	o := newBatchOptions(opts)
+	return runBatch(ctx, ids, o, func(ctx context.Context, id string) (*datatypes.StatusResponse, error) {
+		return s.AllSubscriptionStatuses(ctx, bearer, id, status, o.callOpts...)
+	})
+}
+
+func newBatchOptions(opts []BatchOption) batchOptions {
+	o := batchOptions{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 1
+	}
+	return o
+}
+
+// orderedWindow bounds, as a multiple of the concurrency, the results an ordered batch buffers
+// while waiting for a slow call.
+const orderedWindow = 4
+
+func runBatch[T any](ctx context.Context, ids <-chan string, o batchOptions,
+	call func(ctx context.Context, id string) (T, error)) *Batch[T] {
+	b := &Batch[T]{results: make(chan BatchResult[T])}
+	completed := make(chan BatchResult[T])
+	sem := make(chan struct{}, o.concurrency)
+	var window chan struct{}
+	if o.ordered {
+		window = make(chan struct{}, o.concurrency*orderedWindow)
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(completed)
+		}()
+		for i := 0; ; i++ {
+			var id string
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-ids:
+				if !ok {
+					return
+				}
+				id = v
+			}
+			if window != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case window <- struct{}{}:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(i int, id string) {
+				defer wg.Done()
+				v, err := call(ctx, id)
+				completed <- BatchResult[T]{Index: i, ID: id, Value: v, Err: err}
+				<-sem
+			}(i, id)
+		}
+	}()
+
+	go func() {
+		defer close(b.results)
+		pending := make(map[int]BatchResult[T])
+		next := 0
+		for r := range completed {
+			b.summary.Total++
+			if r.Err != nil {
+				b.summary.Failures = append(b.summary.Failures, BatchFailure{Index: r.Index, ID: r.ID, Err: r.Err})
+			} else {
+				b.summary.Succeeded++
+			}
+
+			if !o.ordered {
+				b.results <- r
+				continue
+			}
+			pending[r.Index] = r
+			for {
+				p, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				b.results <- p
+				<-window
+				next++
+			}
+		}
+		sort.Slice(b.summary.Failures, func(i, j int) bool {
+			return b.summary.Failures[i].Index < b.summary.Failures[j].Index
+		})
+		b.summary.Err = ctx.Err()
+	}()
+	return b
+}
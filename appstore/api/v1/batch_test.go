@@ -0,0 +1,124 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServiceBatchAllSubscriptionStatuses(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		// earlier IDs answer later, so completion order differs from input order
+		n64, _ := strconv.Atoi(id)
+		time.Sleep(time.Duration(10-n64%10) * time.Millisecond)
+		if id == "13" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"bundleId":"` + id + `"}`))
+	}))
+	defer srv.Close()
+
+	ids := make([]string, 20)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	ids[5] = "bad/id"
+
+	tests := []struct {
+		name    string
+		opts    []BatchOption
+		ordered bool
+	}{
+		{name: "as completed", opts: []BatchOption{BatchConcurrency(4)}},
+		{name: "ordered", opts: []BatchOption{BatchConcurrency(4), BatchOrdered()}, ordered: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&maxInFlight, 0)
+			s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+			s.BasePath = srv.URL + "/"
+
+			b := s.BatchAllSubscriptionStatuses(context.Background(), "bearer", BatchIDs(ids), 0, tt.opts...)
+			var indexes []int
+			for r := range b.Results() {
+				indexes = append(indexes, r.Index)
+				if r.Err == nil && r.Value.BundleID != r.ID {
+					t.Errorf("result %d = %+v, want bundle %s", r.Index, r.Value, r.ID)
+				}
+			}
+			summary := b.Wait()
+
+			if len(indexes) != len(ids) {
+				t.Fatalf("got %d results, want %d", len(indexes), len(ids))
+			}
+			if tt.ordered {
+				for i, idx := range indexes {
+					if idx != i {
+						t.Fatalf("results order = %v, want input order", indexes)
+					}
+				}
+			}
+			if got := atomic.LoadInt32(&maxInFlight); got > 4 {
+				t.Errorf("max concurrent calls = %d, want at most 4", got)
+			}
+			if summary.Total != 20 || summary.Succeeded != 18 || len(summary.Failures) != 2 || summary.Err != nil {
+				t.Fatalf("Wait() = %+v", summary)
+			}
+			if f := summary.Failures[0]; f.Index != 5 || !errors.Is(f.Err, ErrInvalidIdentifier) {
+				t.Errorf("Failures[0] = %+v, want invalid identifier at 5", f)
+			}
+			if f := summary.Failures[1]; f.ID != "13" {
+				t.Errorf("Failures[1] = %+v, want 13", f)
+			}
+		})
+	}
+}
+
+func TestServiceBatchCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+	s.BasePath = srv.URL + "/"
+
+	ids := make(chan string) // never closed, the batch ends with ctx
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case ids <- strconv.Itoa(i):
+			case <-time.After(time.Second):
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	summary := s.BatchTransactionInfo(ctx, "bearer", ids, BatchConcurrency(2)).Wait()
+	if !errors.Is(summary.Err, context.DeadlineExceeded) {
+		t.Errorf("Wait().Err = %v, want deadline exceeded", summary.Err)
+	}
+	if summary.Total == 0 || summary.Total > 10 {
+		t.Errorf("Wait().Total = %d, want a few calls", summary.Total)
+	}
+}
@@ -0,0 +1,207 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// ErrCircuitOpen is returned without calling Apple while the circuit breaker of an endpoint is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState see https://martinfowler.com/bliki/CircuitBreaker.html
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreakerConfig opens the circuit after FailureThreshold consecutive failures: 5xx responses, timeouts and
+// transport errors such as a refused connection. Calls canceled by the caller count neither way. The circuit
+// lets HalfOpenMaxCalls trial requests through after OpenTimeout, and closes it again when they succeed.
+type CircuitBreakerConfig struct {
+	FailureThreshold int                                            // default 5
+	OpenTimeout      time.Duration                                  // default 30s
+	HalfOpenMaxCalls int                                            // default 1
+	OnStateChange    func(endpoint Endpoint, from, to CircuitState) // called synchronously without holding the breaker's lock, keep it fast
+}
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	cfg      CircuitBreakerConfig
+	endpoint Endpoint
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	inFlight int
+	now      func() time.Time
+}
+
+func newCircuitBreaker(endpoint Endpoint, cfg CircuitBreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = 1
+	}
+	return &circuitBreaker{cfg: cfg, endpoint: endpoint, now: time.Now}
+}
+
+// allow reports whether a request may be sent, every allowed request must be followed by done.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	change, err := b.allowLocked()
+	b.mu.Unlock()
+	b.notify(change)
+	return err
+}
+
+func (b *circuitBreaker) allowLocked() (*stateChange, error) {
+	var change *stateChange
+	if b.state == CircuitOpen {
+		if b.now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, b.endpoint)
+		}
+		change = b.setState(CircuitHalfOpen)
+	}
+	if b.state == CircuitHalfOpen && b.inFlight >= b.cfg.HalfOpenMaxCalls {
+		return change, fmt.Errorf("%w: %s", ErrCircuitOpen, b.endpoint)
+	}
+	b.inFlight++
+	return change, nil
+}
+
+// done records the outcome of an allowed request. A request that was never sent, or whose outcome says nothing
+// about the endpoint, is recorded with sent false.
+func (b *circuitBreaker) done(sent, failed bool) {
+	b.mu.Lock()
+	change := b.doneLocked(sent, failed)
+	b.mu.Unlock()
+	b.notify(change)
+}
+
+func (b *circuitBreaker) doneLocked(sent, failed bool) *stateChange {
+	b.inFlight--
+	if !sent {
+		return nil
+	}
+	if !failed {
+		b.failures = 0
+		if b.state == CircuitHalfOpen {
+			return b.setState(CircuitClosed)
+		}
+		return nil
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.openedAt = b.now()
+		return b.setState(CircuitOpen)
+	}
+	return nil
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// stateChange is a transition recorded under b.mu, reported to OnStateChange once it is released.
+type stateChange struct {
+	from, to CircuitState
+}
+
+// setState must be called with b.mu held, it returns nil when the state doesn't change.
+func (b *circuitBreaker) setState(to CircuitState) *stateChange {
+	from := b.state
+	if from == to {
+		return nil
+	}
+	b.state = to
+	if to == CircuitClosed {
+		b.failures = 0
+	}
+	return &stateChange{from: from, to: to}
+}
+
+// notify calls OnStateChange without holding b.mu, so the callback may read the state back.
+func (b *circuitBreaker) notify(change *stateChange) {
+	if change != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(b.endpoint, change.from, change.to)
+	}
+}
+
+// circuitOutcome reports whether an attempt counts for the circuit, and whether as a failure: 5xx responses,
+// timeouts, transport errors and bodies that can't be read fail. An attempt canceled by its caller doesn't count.
+func circuitOutcome(resp *http.Response, err error) (counted, failed bool) {
+	if errors.Is(err, context.Canceled) {
+		return false, false
+	}
+	if resp != nil {
+		var errResp *datatypes.ErrorResponse
+		return true, resp.StatusCode >= http.StatusInternalServerError || err != nil && !errors.As(err, &errResp)
+	}
+	return true, err != nil
+}
+
+// circuitBreakers holds one breaker per endpoint, created on first use.
+type circuitBreakers struct {
+	mu          sync.Mutex
+	def         *CircuitBreakerConfig
+	perEndpoint map[Endpoint]CircuitBreakerConfig
+	breakers    map[Endpoint]*circuitBreaker
+}
+
+func (c *circuitBreakers) get(endpoint Endpoint) *circuitBreaker {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if b, ok := c.breakers[endpoint]; ok {
+		return b
+	}
+	var b *circuitBreaker
+	if cfg, ok := c.perEndpoint[endpoint]; ok {
+		b = newCircuitBreaker(endpoint, cfg)
+	} else if c.def != nil {
+		b = newCircuitBreaker(endpoint, *c.def)
+	}
+	if c.breakers == nil {
+		c.breakers = make(map[Endpoint]*circuitBreaker)
+	}
+	c.breakers[endpoint] = b
+	return b
+}
+
+// CircuitState returns the state of the circuit breaker of endpoint, CircuitClosed when there is none.
+func (s *Service) CircuitState(endpoint Endpoint) CircuitState {
+	if b := s.breakers.get(endpoint); b != nil {
+		return b.currentState()
+	}
+	return CircuitClosed
+}
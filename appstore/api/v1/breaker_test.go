@@ -0,0 +1,166 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	now := time.Unix(0, 0)
+	var changes []CircuitState
+	b := newCircuitBreaker(EndpointTransactionInfo, CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		OnStateChange: func(_ Endpoint, _, to CircuitState) {
+			changes = append(changes, to)
+		},
+	})
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow() closed error = %v", err)
+		}
+		b.done(true, true)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() open error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow() half-open error = %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("allow() second half-open call error = %v, want %v", err, ErrCircuitOpen)
+	}
+	b.done(true, false)
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(changes) != len(want) {
+		t.Fatalf("state changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("state changes = %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestServiceCircuitBreaker(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithRetry(time.Millisecond, time.Millisecond),
+		WithEndpointCircuitBreaker(EndpointTransactionHistory, CircuitBreakerConfig{FailureThreshold: 3}),
+	)
+	s.BasePath = srv.URL + "/"
+
+	if _, err := s.TransactionHistory(context.Background(), "bearer", "1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("TransactionHistory() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("TransactionHistory() calls = %d, want 3", got)
+	}
+	if got := s.CircuitState(EndpointTransactionHistory); got != CircuitOpen {
+		t.Errorf("CircuitState() = %v, want %v", got, CircuitOpen)
+	}
+	if got := s.CircuitState(EndpointTransactionInfo); got != CircuitClosed {
+		t.Errorf("CircuitState() other endpoint = %v, want %v", got, CircuitClosed)
+	}
+}
+
+func TestCircuitBreakerStateChangeReadsState(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var s *Service
+	var seen []CircuitState
+	s = NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithEndpointCircuitBreaker(EndpointTransactionInfo, CircuitBreakerConfig{
+			FailureThreshold: 1,
+			OnStateChange: func(endpoint Endpoint, _, _ CircuitState) {
+				seen = append(seen, s.CircuitState(endpoint))
+			},
+		}),
+	)
+	s.BasePath = srv.URL + "/"
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = s.TransactionInfo(context.Background(), "bearer", "1")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("TransactionInfo() deadlocked in OnStateChange")
+	}
+	if len(seen) != 1 || seen[0] != CircuitOpen {
+		t.Errorf("CircuitState() in OnStateChange = %v, want [%v]", seen, CircuitOpen)
+	}
+}
+
+// failingTransport fails every request with err.
+type failingTransport struct{ err error }
+
+func (t *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+func TestCircuitBreakerTransportErrors(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	rt := &failingTransport{err: refused}
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(&http.Client{Transport: rt}),
+		WithEndpointCircuitBreaker(EndpointTransactionInfo, CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute}),
+	)
+	now := time.Unix(0, 0)
+	s.breakers.get(EndpointTransactionInfo).now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.TransactionInfo(context.Background(), "bearer", "1"); !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Fatalf("TransactionInfo() error = %v, want %v", err, syscall.ECONNREFUSED)
+		}
+	}
+	if got := s.CircuitState(EndpointTransactionInfo); got != CircuitOpen {
+		t.Fatalf("CircuitState() after refused connections = %v, want %v", got, CircuitOpen)
+	}
+
+	now = now.Add(time.Minute)
+	rt.err = context.Canceled
+	if _, err := s.TransactionInfo(context.Background(), "bearer", "1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TransactionInfo() canceled error = %v", err)
+	}
+	if got := s.CircuitState(EndpointTransactionInfo); got != CircuitHalfOpen {
+		t.Errorf("CircuitState() after a canceled trial = %v, want %v", got, CircuitHalfOpen)
+	}
+
+	rt.err = refused
+	if _, err := s.TransactionInfo(context.Background(), "bearer", "1"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("TransactionInfo() half-open error = %v, want %v", err, syscall.ECONNREFUSED)
+	}
+	if got := s.CircuitState(EndpointTransactionInfo); got != CircuitOpen {
+		t.Errorf("CircuitState() after a refused trial = %v, want %v", got, CircuitOpen)
+	}
+	if _, err := s.TransactionInfo(context.Background(), "bearer", "1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("TransactionInfo() reopened error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
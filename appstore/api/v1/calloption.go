@@ -0,0 +1,164 @@
+package appstoreapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// CallOption overrides the settings of Service for a single call.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout        time.Duration
+	attemptTimeout time.Duration
+	retryPolicy    RetryPolicy
+	backoff        BackoffFactory
+	environment    datatypes.Environment
+	header         http.Header
+	meta           *ResponseMeta
+}
+
+// CallTimeout bounds the whole call including retries, in place of WithTimeout.
+func CallTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = d
+	}
+}
+
+// CallAttemptTimeout bounds every attempt of the call, reading the response body included, in place of
+// WithAttemptTimeout.
+func CallAttemptTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.attemptTimeout = d
+	}
+}
+
+// TimeoutInterceptor bounds every attempt by d until its response body is closed, or the whole call with
+// its retries when added by WithCallInterceptors. It applies on top of CallAttemptTimeout and CallTimeout,
+// which can only shorten it, use WithAttemptTimeout or WithTimeout for a default calls can extend.
+func TimeoutInterceptor(d time.Duration) Interceptor {
+	return func(_ *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		return withAttemptTimeout(req, d, next)
+	}
+}
+
+// CallRetryPolicy is ContextWithRetryPolicy for this call only, it replaces the policy of ctx. p retries the
+// call even if Service doesn't retry, NoRetry disables retries.
+func CallRetryPolicy(p RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retryPolicy = p
+	}
+}
+
+// CallBackoff pauses between the retries of the call with f.
+func CallBackoff(f BackoffFactory) CallOption {
+	return func(o *callOptions) {
+		o.backoff = f
+	}
+}
+
+// CallEnvironment sends the call to env, resolved by the EnvironmentResolver of Service.
+func CallEnvironment(env datatypes.Environment) CallOption {
+	return func(o *callOptions) {
+		o.environment = env
+	}
+}
+
+// CallHeader adds a header to the request of the call.
+func CallHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// CallResponseMeta fills meta once the call returns, see ContextWithResponseMeta.
+func CallResponseMeta(meta *ResponseMeta) CallOption {
+	return func(o *callOptions) {
+		o.meta = meta
+	}
+}
+
+type callOptionsKey struct{}
+
+// withCallOptions returns ctx carrying opts, bounded by the timeout of the call or else of s. cancel must be
+// called once the response body is consumed.
+func (s *Service) withCallOptions(ctx context.Context, opts []CallOption) (context.Context, context.CancelFunc) {
+	if len(opts) == 0 {
+		if s.timeout > 0 {
+			return context.WithTimeout(ctx, s.timeout)
+		}
+		return ctx, func() {}
+	}
+
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.retryPolicy != nil {
+		ctx = ContextWithRetryPolicy(ctx, o.retryPolicy)
+	}
+	if o.meta != nil {
+		ctx = ContextWithResponseMeta(ctx, o.meta)
+	}
+	ctx = context.WithValue(ctx, callOptionsKey{}, &o)
+	if o.timeout <= 0 {
+		o.timeout = s.timeout
+	}
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return ctx, func() {}
+}
+
+func callOptionsFromContext(ctx context.Context) *callOptions {
+	o, _ := ctx.Value(callOptionsKey{}).(*callOptions)
+	if o == nil {
+		return &callOptions{}
+	}
+	return o
+}
+
+// basePath returns the base path of the environment selected for the call made with ctx.
+func (s *Service) basePath(ctx context.Context) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	env := callOptionsFromContext(ctx).environment
+	if env == "" || env == s.env {
+		return s.BasePath, nil
+	}
+	if s.resolver == nil {
+		return DefaultResolver.BaseURL(env)
+	}
+	return s.resolver.BaseURL(env)
+}
+
+// withAttemptTimeout bounds req by d until the body of its response is closed.
+func withAttemptTimeout(req *http.Request, d time.Duration, do Invoker) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), d)
+	resp, err := do(req.WithContext(ctx))
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, err
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,152 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestCallOptions(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tenant") != "acme" {
+			t.Errorf("X-Tenant header = %q", r.Header.Get("X-Tenant"))
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte(`{"revision":"1"}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+	s.BasePath = srv.URL + "/"
+
+	var meta ResponseMeta
+	rsp, err := s.TransactionHistory(context.Background(), "bearer", "1",
+		CallAttemptTimeout(50*time.Millisecond),
+		CallRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 3}),
+		CallBackoff(func() Backoff { return &constantBackoff{} }),
+		CallHeader("X-Tenant", "acme"),
+		CallResponseMeta(&meta),
+	)
+	if err != nil {
+		t.Fatalf("TransactionHistory() error = %v", err)
+	}
+	if rsp.Revision != "1" {
+		t.Errorf("TransactionHistory() revision = %q", rsp.Revision)
+	}
+	if meta.Attempts != 2 {
+		t.Errorf("ResponseMeta.Attempts = %d, want 2", meta.Attempts)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+	s.BasePath = srv.URL + "/"
+
+	_, err := s.LookUpOrderID(context.Background(), "bearer", "order", CallTimeout(20*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LookUpOrderID() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestServiceDefaultTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			_, _ = w.Write([]byte(`{}`))
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		opt     Option
+		callOpt CallOption
+	}{
+		{name: "call", opt: WithTimeout(20 * time.Millisecond), callOpt: CallTimeout(time.Second)},
+		{name: "attempt", opt: WithAttemptTimeout(20 * time.Millisecond), callOpt: CallAttemptTimeout(time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewAppStoreService(context.Background(), tt.opt, WithHTTPClient(srv.Client()))
+			s.BasePath = srv.URL + "/"
+
+			if _, err := s.LookUpOrderID(context.Background(), "bearer", "order"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("LookUpOrderID() error = %v, want the service default %v", err, context.DeadlineExceeded)
+			}
+			if _, err := s.LookUpOrderID(context.Background(), "bearer", "order", tt.callOpt); err != nil {
+				t.Errorf("LookUpOrderID() with a longer call timeout error = %v", err)
+			}
+		})
+	}
+}
+
+func TestCallEnvironment(t *testing.T) {
+	s := NewAppStoreService(context.Background())
+	ctx, cancel := s.withCallOptions(context.Background(), []CallOption{CallEnvironment(datatypes.Sandbox)})
+	defer cancel()
+
+	if got, _ := s.basePath(ctx); got != datatypes.SandboxBasePath {
+		t.Errorf("basePath() = %v, want %v", got, datatypes.SandboxBasePath)
+	}
+	if got := s.environment(ctx); got != datatypes.Sandbox {
+		t.Errorf("environment() = %v, want %v", got, datatypes.Sandbox)
+	}
+	if got, _ := s.basePath(context.Background()); got != datatypes.BasePath {
+		t.Errorf("basePath() without options = %v, want %v", got, datatypes.BasePath)
+	}
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		opt       Option
+		wantCalls int32
+	}{
+		{name: "attempt", opt: WithInterceptors(TimeoutInterceptor(20 * time.Millisecond)), wantCalls: 3},
+		{name: "call", opt: WithCallInterceptors(TimeoutInterceptor(20 * time.Millisecond)), wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			s := NewAppStoreService(context.Background(), tt.opt, WithHTTPClient(srv.Client()),
+				WithRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 3}), WithRetry(time.Millisecond, time.Millisecond))
+			s.BasePath = srv.URL + "/"
+
+			start := time.Now()
+			if _, err := s.TransactionInfo(context.Background(), "bearer", "1"); err == nil {
+				t.Error("TransactionInfo() error = nil, want a timeout")
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("TransactionInfo() took %v", elapsed)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package appstoreapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// flightGroup merges concurrent calls with the same key, see WithCoalescing.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// do calls fn, or waits for the call with the same key in flight and shares its result.
+// A waiter whose own ctx is still alive calls fn itself when the shared call was cancelled.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if isContextError(f.err) && ctx.Err() == nil {
+			return fn()
+		}
+		return f.body, f.err
+	}
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	f.body, f.err = fn()
+
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+	close(f.done)
+	return f.body, f.err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// coalescable reports whether a call made with ctx may share the result of another one.
+func coalescable(ctx context.Context) bool {
+	return ctx.Value(callOptionsKey{}) == nil && responseMetaFromContext(ctx) == nil
+}
+
+// flightKey identifies req by endpoint, environment, URL and bearer, hashed so it isn't kept in memory.
+func flightKey(endpoint Endpoint, env datatypes.Environment, req *http.Request) string {
+	auth := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+	return string(endpoint) + " " + string(env) + " " + req.URL.String() + " " + hex.EncodeToString(auth[:])
+}
@@ -0,0 +1,117 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServiceCoalescing(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"bundleId":"com.example.app"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		bearer    func(i int) string
+		wantCalls int32
+	}{
+		{
+			name:      "coalesced",
+			opts:      []Option{WithCoalescing()},
+			bearer:    func(int) string { return "bearer" },
+			wantCalls: 1,
+		},
+		{
+			name:      "one call per bearer",
+			opts:      []Option{WithCoalescing()},
+			bearer:    func(i int) string { return []string{"a", "b"}[i%2] },
+			wantCalls: 2,
+		},
+		{
+			name:      "disabled",
+			bearer:    func(int) string { return "bearer" },
+			wantCalls: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			s := NewAppStoreService(context.Background(), append(tt.opts, WithHTTPClient(srv.Client()))...)
+			s.BasePath = srv.URL + "/"
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					got, err := s.AllSubscriptionStatuses(context.Background(), tt.bearer(i), "1", 0)
+					if err != nil {
+						t.Errorf("AllSubscriptionStatuses() error = %v", err)
+						return
+					}
+					if got.BundleID != "com.example.app" {
+						t.Errorf("AllSubscriptionStatuses() BundleID = %q", got.BundleID)
+					}
+				}(i)
+			}
+			wg.Wait()
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_, _ = g.do(context.Background(), "key", func() ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("shared"), nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.do(ctx, "key", func() ([]byte, error) { return nil, nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("do() error = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+
+	// the leader's cancellation doesn't fail a waiter whose context is alive
+	leader, cancelLeader := context.WithCancel(context.Background())
+	started = make(chan struct{})
+	go func() {
+		_, _ = g.do(leader, "other", func() ([]byte, error) {
+			close(started)
+			<-leader.Done()
+			return nil, leader.Err()
+		})
+	}()
+	<-started
+	done := make(chan []byte)
+	go func() {
+		body, _ := g.do(context.Background(), "other", func() ([]byte, error) { return []byte("own"), nil })
+		done <- body
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancelLeader()
+	if got := string(<-done); got != "own" {
+		t.Errorf("do() = %q, want %q", got, "own")
+	}
+}
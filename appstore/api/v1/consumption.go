@@ -0,0 +1,259 @@
+package appstoreapi
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// Amount is a sum of money, Value is a decimal like "49.99" and Currency an ISO 4217 code like "USD".
+type Amount struct {
+	Value    string
+	Currency string
+}
+
+// USD returns value US dollars, e.g. USD("49.99").
+func USD(value string) Amount {
+	return Amount{Value: value, Currency: "USD"}
+}
+
+// CurrencyConverter converts amount of currency to US dollars, to bucket lifetime amounts not in USD.
+type CurrencyConverter func(amount *big.Rat, currency string) (*big.Rat, error)
+
+const day = 24 * time.Hour
+
+// AccountTenureOf returns the bucket of an account age.
+// see https://developer.apple.com/documentation/appstoreserverapi/accounttenure
+func AccountTenureOf(age time.Duration) datatypes.AccountTenure {
+	switch {
+	case age < 3*day:
+		return datatypes.AccountTenure0To3Days
+	case age < 10*day:
+		return datatypes.AccountTenure3To10Days
+	case age < 30*day:
+		return datatypes.AccountTenure10To30Days
+	case age < 90*day:
+		return datatypes.AccountTenure30To90Days
+	case age < 180*day:
+		return datatypes.AccountTenure90To180Days
+	case age < 365*day:
+		return datatypes.AccountTenure180To365Days
+	default:
+		return datatypes.AccountTenureOver365Days
+	}
+}
+
+// PlayTimeOf returns the bucket of the time spent using the app.
+// see https://developer.apple.com/documentation/appstoreserverapi/playtime
+func PlayTimeOf(d time.Duration) datatypes.PlayTime {
+	switch {
+	case d < 5*time.Minute:
+		return datatypes.PlayTime0To5Minutes
+	case d < time.Hour:
+		return datatypes.PlayTime5To60Minutes
+	case d < 6*time.Hour:
+		return datatypes.PlayTime1To6Hours
+	case d < day:
+		return datatypes.PlayTime6To24Hours
+	case d < 4*day:
+		return datatypes.PlayTime1To4Days
+	case d < 16*day:
+		return datatypes.PlayTime4To16Days
+	default:
+		return datatypes.PlayTimeOver16Days
+	}
+}
+
+// lifetimeDollarsBounds are the upper bounds, exclusive, of the buckets after LifetimeDollarsZero.
+var lifetimeDollarsBounds = []struct {
+	below  *big.Rat
+	bucket datatypes.LifetimeDollars
+}{
+	{big.NewRat(50, 1), datatypes.LifetimeDollars1To49},
+	{big.NewRat(100, 1), datatypes.LifetimeDollars50To99},
+	{big.NewRat(500, 1), datatypes.LifetimeDollars100To499},
+	{big.NewRat(1000, 1), datatypes.LifetimeDollars500To999},
+	{big.NewRat(2000, 1), datatypes.LifetimeDollars1000To1999},
+}
+
+// LifetimeDollarsOf returns the bucket of a lifetime amount in US dollars, usd must not be negative.
+// see https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarspurchased
+func LifetimeDollarsOf(usd *big.Rat) datatypes.LifetimeDollars {
+	if usd.Sign() == 0 {
+		return datatypes.LifetimeDollarsZero
+	}
+	for _, b := range lifetimeDollarsBounds {
+		if usd.Cmp(b.below) < 0 {
+			return b.bucket
+		}
+	}
+	return datatypes.LifetimeDollarsOver2000
+}
+
+var decimalPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ConsumptionBuilder maps raw usage metrics to a ConsumptionRequest answering a CONSUMPTION_REQUEST notification.
+// Delivery must be set, the zero DeliveryStatus reports the purchase as delivered and working. The other fields
+// not set are sent as undeclared.
+//
+//	cr, err := appstoreapi.NewConsumptionBuilder().
+//		CustomerConsented(true).
+//		AccountAge(time.Since(user.CreatedAt)).
+//		PlayTime(user.TimePlayed).
+//		LifetimePurchased(appstoreapi.USD("129.99")).
+//		Platform(datatypes.PlatformApple).
+//		Delivery(datatypes.DeliveredAndWorking).
+//		Build()
+type ConsumptionBuilder struct {
+	cr      datatypes.ConsumptionRequest
+	convert CurrencyConverter
+	errs    []error
+
+	deliverySet bool
+}
+
+// NewConsumptionBuilder returns a builder with no metric set.
+func NewConsumptionBuilder() *ConsumptionBuilder {
+	return &ConsumptionBuilder{}
+}
+
+// ConvertWith converts the lifetime amounts not in USD with convert, they are rejected without one.
+func (b *ConsumptionBuilder) ConvertWith(convert CurrencyConverter) *ConsumptionBuilder {
+	b.convert = convert
+	return b
+}
+
+// CustomerConsented must be true, Apple rejects consumption data sent without the customer's consent.
+func (b *ConsumptionBuilder) CustomerConsented(consented bool) *ConsumptionBuilder {
+	b.cr.CustomerConsented = consented
+	return b
+}
+
+// AppAccountToken is the UUID the app set on the purchase, if any.
+func (b *ConsumptionBuilder) AppAccountToken(token string) *ConsumptionBuilder {
+	b.cr.AppAccountToken = token
+	return b
+}
+
+// AccountAge is the time since the customer's account was created.
+func (b *ConsumptionBuilder) AccountAge(age time.Duration) *ConsumptionBuilder {
+	if age < 0 {
+		b.invalid("account age %v is negative", age)
+		return b
+	}
+	b.cr.AccountTenure = AccountTenureOf(age)
+	return b
+}
+
+// PlayTime is the time the customer spent using the app.
+func (b *ConsumptionBuilder) PlayTime(d time.Duration) *ConsumptionBuilder {
+	if d < 0 {
+		b.invalid("play time %v is negative", d)
+		return b
+	}
+	b.cr.PlayTime = PlayTimeOf(d)
+	return b
+}
+
+// LifetimePurchased is the total the customer spent in the app.
+func (b *ConsumptionBuilder) LifetimePurchased(a Amount) *ConsumptionBuilder {
+	if bucket, err := b.lifetimeDollars(a); err != nil {
+		b.invalid("lifetime purchased: %v", err)
+	} else {
+		b.cr.LifetimeDollarsPurchased = bucket
+	}
+	return b
+}
+
+// LifetimeRefunded is the total refunded to the customer by the app.
+func (b *ConsumptionBuilder) LifetimeRefunded(a Amount) *ConsumptionBuilder {
+	if bucket, err := b.lifetimeDollars(a); err != nil {
+		b.invalid("lifetime refunded: %v", err)
+	} else {
+		b.cr.LifetimeDollarsRefunded = bucket
+	}
+	return b
+}
+
+// Platform is where the customer consumed the purchase.
+func (b *ConsumptionBuilder) Platform(p datatypes.Platform) *ConsumptionBuilder {
+	b.cr.Platform = p
+	return b
+}
+
+// Delivery is the outcome of delivering the purchase.
+func (b *ConsumptionBuilder) Delivery(s datatypes.DeliveryStatus) *ConsumptionBuilder {
+	b.cr.DeliveryStatus = s
+	b.deliverySet = true
+	return b
+}
+
+// Consumption is how much of the purchase the customer consumed.
+func (b *ConsumptionBuilder) Consumption(s datatypes.ConsumptionStatus) *ConsumptionBuilder {
+	b.cr.ConsumptionStatus = s
+	return b
+}
+
+// UserStatus is the status of the customer's account.
+func (b *ConsumptionBuilder) UserStatus(s datatypes.UserStatus) *ConsumptionBuilder {
+	b.cr.UserStatus = s
+	return b
+}
+
+// RefundPreference is the app's preference for the outcome of the refund request.
+func (b *ConsumptionBuilder) RefundPreference(p datatypes.RefundPreference) *ConsumptionBuilder {
+	b.cr.RefundPreference = p
+	return b
+}
+
+// SampleContentProvided tells whether the app offered a free sample or trial before the purchase.
+func (b *ConsumptionBuilder) SampleContentProvided(provided bool) *ConsumptionBuilder {
+	b.cr.SampleContentProvided = provided
+	return b
+}
+
+// Build returns the ConsumptionRequest, or every invalid metric and field and a missing Delivery, each matching
+// datatypes.ErrInvalidConsumptionRequest.
+func (b *ConsumptionBuilder) Build() (*datatypes.ConsumptionRequest, error) {
+	cr := b.cr
+	errs := b.errs[:len(b.errs):len(b.errs)]
+	if !b.deliverySet {
+		errs = append(errs, fmt.Errorf("%w: delivery status not set, it has no undeclared value", datatypes.ErrInvalidConsumptionRequest))
+	}
+	if err := errors.Join(append(errs, cr.Validate())...); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (b *ConsumptionBuilder) invalid(format string, a ...any) {
+	b.errs = append(b.errs, fmt.Errorf("%w: %s", datatypes.ErrInvalidConsumptionRequest, fmt.Sprintf(format, a...)))
+}
+
+// lifetimeDollars returns the bucket of a, converted to US dollars.
+func (b *ConsumptionBuilder) lifetimeDollars(a Amount) (datatypes.LifetimeDollars, error) {
+	if !decimalPattern.MatchString(a.Value) {
+		return 0, fmt.Errorf("%q is not a decimal like \"49.99\"", a.Value)
+	}
+	v, _ := new(big.Rat).SetString(a.Value)
+	currency := strings.ToUpper(a.Currency)
+	if currency != "USD" {
+		if b.convert == nil {
+			return 0, fmt.Errorf("no currency converter for %q", a.Currency)
+		}
+		usd, err := b.convert(v, currency)
+		if err != nil {
+			return 0, err
+		}
+		if usd.Sign() < 0 {
+			return 0, fmt.Errorf("%s %s converted to a negative amount", a.Value, a.Currency)
+		}
+		v = usd
+	}
+	return LifetimeDollarsOf(v), nil
+}
@@ -0,0 +1,195 @@
+package appstoreapi
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestAccountTenureOf(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want datatypes.AccountTenure
+	}{
+		{0, datatypes.AccountTenure0To3Days},
+		{3*day - time.Second, datatypes.AccountTenure0To3Days},
+		{3 * day, datatypes.AccountTenure3To10Days},
+		{10 * day, datatypes.AccountTenure10To30Days},
+		{30 * day, datatypes.AccountTenure30To90Days},
+		{90 * day, datatypes.AccountTenure90To180Days},
+		{180 * day, datatypes.AccountTenure180To365Days},
+		{365*day - time.Second, datatypes.AccountTenure180To365Days},
+		{365 * day, datatypes.AccountTenureOver365Days},
+	}
+	for _, tt := range tests {
+		if got := AccountTenureOf(tt.age); got != tt.want {
+			t.Errorf("AccountTenureOf(%v) = %d, want %d", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestPlayTimeOf(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want datatypes.PlayTime
+	}{
+		{0, datatypes.PlayTime0To5Minutes},
+		{5*time.Minute - time.Second, datatypes.PlayTime0To5Minutes},
+		{5 * time.Minute, datatypes.PlayTime5To60Minutes},
+		{time.Hour, datatypes.PlayTime1To6Hours},
+		{6 * time.Hour, datatypes.PlayTime6To24Hours},
+		{day, datatypes.PlayTime1To4Days},
+		{4 * day, datatypes.PlayTime4To16Days},
+		{16*day - time.Second, datatypes.PlayTime4To16Days},
+		{16 * day, datatypes.PlayTimeOver16Days},
+	}
+	for _, tt := range tests {
+		if got := PlayTimeOf(tt.d); got != tt.want {
+			t.Errorf("PlayTimeOf(%v) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestLifetimeDollarsOf(t *testing.T) {
+	tests := []struct {
+		usd  string
+		want datatypes.LifetimeDollars
+	}{
+		{"0", datatypes.LifetimeDollarsZero},
+		{"0.00", datatypes.LifetimeDollarsZero},
+		{"0.01", datatypes.LifetimeDollars1To49},
+		{"49.99", datatypes.LifetimeDollars1To49},
+		{"49.999", datatypes.LifetimeDollars1To49},
+		{"50", datatypes.LifetimeDollars50To99},
+		{"99.99", datatypes.LifetimeDollars50To99},
+		{"100", datatypes.LifetimeDollars100To499},
+		{"500", datatypes.LifetimeDollars500To999},
+		{"1000", datatypes.LifetimeDollars1000To1999},
+		{"1999.99", datatypes.LifetimeDollars1000To1999},
+		{"2000", datatypes.LifetimeDollarsOver2000},
+	}
+	for _, tt := range tests {
+		usd, _ := new(big.Rat).SetString(tt.usd)
+		if got := LifetimeDollarsOf(usd); got != tt.want {
+			t.Errorf("LifetimeDollarsOf(%s) = %d, want %d", tt.usd, got, tt.want)
+		}
+	}
+}
+
+func TestConsumptionBuilder(t *testing.T) {
+	// 1 EUR is 1.1 USD
+	toUSD := func(amount *big.Rat, currency string) (*big.Rat, error) {
+		if currency != "EUR" {
+			return nil, errors.New("unsupported currency " + currency)
+		}
+		return new(big.Rat).Mul(amount, big.NewRat(11, 10)), nil
+	}
+
+	tests := []struct {
+		name     string
+		build    func(b *ConsumptionBuilder) *ConsumptionBuilder
+		want     *datatypes.ConsumptionRequest
+		wantErrs []string
+	}{
+		{
+			name: "undeclared",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				return b.CustomerConsented(true).Delivery(datatypes.NotDeliveredServerOutage)
+			},
+			want: &datatypes.ConsumptionRequest{CustomerConsented: true, DeliveryStatus: datatypes.NotDeliveredServerOutage},
+		},
+		{
+			name: "missing delivery status",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				return b.CustomerConsented(true).Platform(datatypes.PlatformApple)
+			},
+			wantErrs: []string{"delivery status not set"},
+		},
+		{
+			name: "every metric",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				return b.CustomerConsented(true).
+					AppAccountToken("7e3fb20b-4cdb-47cc-936d-99d65f608138").
+					AccountAge(400 * day).
+					PlayTime(90 * time.Minute).
+					LifetimePurchased(USD("129.99")).
+					LifetimeRefunded(USD("0")).
+					Platform(datatypes.PlatformApple).
+					Delivery(datatypes.DeliveredAndWorking).
+					Consumption(datatypes.ConsumptionStatusFullyConsumed).
+					UserStatus(datatypes.UserStatusActive).
+					RefundPreference(datatypes.RefundPreferenceDecline).
+					SampleContentProvided(true)
+			},
+			want: &datatypes.ConsumptionRequest{
+				AccountTenure:            datatypes.AccountTenureOver365Days,
+				AppAccountToken:          "7e3fb20b-4cdb-47cc-936d-99d65f608138",
+				ConsumptionStatus:        datatypes.ConsumptionStatusFullyConsumed,
+				CustomerConsented:        true,
+				DeliveryStatus:           datatypes.DeliveredAndWorking,
+				LifetimeDollarsPurchased: datatypes.LifetimeDollars100To499,
+				LifetimeDollarsRefunded:  datatypes.LifetimeDollarsZero,
+				Platform:                 datatypes.PlatformApple,
+				PlayTime:                 datatypes.PlayTime1To6Hours,
+				RefundPreference:         datatypes.RefundPreferenceDecline,
+				SampleContentProvided:    true,
+				UserStatus:               datatypes.UserStatusActive,
+			},
+		},
+		{
+			name: "converted currency",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				// 46 EUR is 50.6 USD
+				return b.CustomerConsented(true).Delivery(datatypes.DeliveredAndWorking).ConvertWith(toUSD).
+					LifetimePurchased(Amount{Value: "46", Currency: "eur"})
+			},
+			want: &datatypes.ConsumptionRequest{CustomerConsented: true, LifetimeDollarsPurchased: datatypes.LifetimeDollars50To99},
+		},
+		{
+			name: "invalid metrics",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				return b.AccountAge(-time.Hour).
+					PlayTime(-time.Second).
+					LifetimePurchased(USD("-5")).
+					LifetimeRefunded(Amount{Value: "10", Currency: "EUR"}).
+					Delivery(datatypes.DeliveryStatus(9))
+			},
+			wantErrs: []string{"account age", "play time", "lifetime purchased", `no currency converter for "EUR"`,
+				"customerConsented", "deliveryStatus"},
+		},
+		{
+			name: "conversion failure",
+			build: func(b *ConsumptionBuilder) *ConsumptionBuilder {
+				return b.CustomerConsented(true).ConvertWith(toUSD).LifetimeRefunded(Amount{Value: "10", Currency: "JPY"})
+			},
+			wantErrs: []string{"unsupported currency JPY"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build(NewConsumptionBuilder()).Build()
+			if tt.wantErrs == nil {
+				if err != nil {
+					t.Fatalf("Build() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Build() = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			if !errors.Is(err, datatypes.ErrInvalidConsumptionRequest) {
+				t.Fatalf("Build() error = %v, want %v", err, datatypes.ErrInvalidConsumptionRequest)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Build() error = %v, want %q reported", err, want)
+				}
+			}
+		})
+	}
+}
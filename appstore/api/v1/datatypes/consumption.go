@@ -0,0 +1,162 @@
+package datatypes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrInvalidConsumptionRequest is matched by the errors of ConsumptionRequest.Validate.
+var ErrInvalidConsumptionRequest = errors.New("invalid consumption request")
+
+// ConsumptionRequest see https://developer.apple.com/documentation/appstoreserverapi/consumptionrequest
+// Every field but RefundPreference is required, zero values are sent.
+type ConsumptionRequest struct {
+	AccountTenure            AccountTenure     `json:"accountTenure"`
+	AppAccountToken          string            `json:"appAccountToken"` // UUID, empty if the app doesn't set one
+	ConsumptionStatus        ConsumptionStatus `json:"consumptionStatus"`
+	CustomerConsented        bool              `json:"customerConsented"` // must be true
+	DeliveryStatus           DeliveryStatus    `json:"deliveryStatus"`
+	LifetimeDollarsPurchased LifetimeDollars   `json:"lifetimeDollarsPurchased"`
+	LifetimeDollarsRefunded  LifetimeDollars   `json:"lifetimeDollarsRefunded"`
+	Platform                 Platform          `json:"platform"`
+	PlayTime                 PlayTime          `json:"playTime"`
+	RefundPreference         RefundPreference  `json:"refundPreference,omitempty"`
+	SampleContentProvided    bool              `json:"sampleContentProvided"`
+	UserStatus               UserStatus        `json:"userStatus"`
+}
+
+// AccountTenure see https://developer.apple.com/documentation/appstoreserverapi/accounttenure
+type AccountTenure int
+
+const (
+	AccountTenureUndeclared   AccountTenure = 0
+	AccountTenure0To3Days     AccountTenure = 1
+	AccountTenure3To10Days    AccountTenure = 2
+	AccountTenure10To30Days   AccountTenure = 3
+	AccountTenure30To90Days   AccountTenure = 4
+	AccountTenure90To180Days  AccountTenure = 5
+	AccountTenure180To365Days AccountTenure = 6
+	AccountTenureOver365Days  AccountTenure = 7
+	accountTenureMax                        = AccountTenureOver365Days
+)
+
+// ConsumptionStatus see https://developer.apple.com/documentation/appstoreserverapi/consumptionstatus
+type ConsumptionStatus int
+
+const (
+	ConsumptionStatusUndeclared        ConsumptionStatus = 0
+	ConsumptionStatusNotConsumed       ConsumptionStatus = 1
+	ConsumptionStatusPartiallyConsumed ConsumptionStatus = 2
+	ConsumptionStatusFullyConsumed     ConsumptionStatus = 3
+	consumptionStatusMax                                 = ConsumptionStatusFullyConsumed
+)
+
+// DeliveryStatus see https://developer.apple.com/documentation/appstoreserverapi/deliverystatus
+type DeliveryStatus int
+
+const (
+	DeliveredAndWorking        DeliveryStatus = 0
+	NotDeliveredQualityIssue   DeliveryStatus = 1
+	DeliveredWrongItem         DeliveryStatus = 2
+	NotDeliveredServerOutage   DeliveryStatus = 3
+	NotDeliveredCurrencyChange DeliveryStatus = 4
+	NotDeliveredOtherReason    DeliveryStatus = 5
+	deliveryStatusMax                         = NotDeliveredOtherReason
+)
+
+// LifetimeDollars see https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarspurchased
+// and https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarsrefunded
+type LifetimeDollars int
+
+const (
+	LifetimeDollarsUndeclared LifetimeDollars = 0
+	LifetimeDollarsZero       LifetimeDollars = 1
+	LifetimeDollars1To49      LifetimeDollars = 2 // 0.01-49.99 USD
+	LifetimeDollars50To99     LifetimeDollars = 3 // 50-99.99 USD
+	LifetimeDollars100To499   LifetimeDollars = 4 // 100-499.99 USD
+	LifetimeDollars500To999   LifetimeDollars = 5 // 500-999.99 USD
+	LifetimeDollars1000To1999 LifetimeDollars = 6 // 1000-1999.99 USD
+	LifetimeDollarsOver2000   LifetimeDollars = 7
+	lifetimeDollarsMax                        = LifetimeDollarsOver2000
+)
+
+// Platform see https://developer.apple.com/documentation/appstoreserverapi/platform
+type Platform int
+
+const (
+	PlatformUndeclared Platform = 0
+	PlatformApple      Platform = 1
+	PlatformNonApple   Platform = 2
+	platformMax                 = PlatformNonApple
+)
+
+// PlayTime see https://developer.apple.com/documentation/appstoreserverapi/playtime
+type PlayTime int
+
+const (
+	PlayTimeUndeclared   PlayTime = 0
+	PlayTime0To5Minutes  PlayTime = 1
+	PlayTime5To60Minutes PlayTime = 2
+	PlayTime1To6Hours    PlayTime = 3
+	PlayTime6To24Hours   PlayTime = 4
+	PlayTime1To4Days     PlayTime = 5
+	PlayTime4To16Days    PlayTime = 6
+	PlayTimeOver16Days   PlayTime = 7
+	playTimeMax                   = PlayTimeOver16Days
+)
+
+// RefundPreference see https://developer.apple.com/documentation/appstoreserverapi/refundpreference
+type RefundPreference int
+
+const (
+	RefundPreferenceUndeclared   RefundPreference = 0
+	RefundPreferenceGrant        RefundPreference = 1
+	RefundPreferenceDecline      RefundPreference = 2
+	RefundPreferenceNoPreference RefundPreference = 3
+	refundPreferenceMax                           = RefundPreferenceNoPreference
+)
+
+// UserStatus see https://developer.apple.com/documentation/appstoreserverapi/userstatus
+type UserStatus int
+
+const (
+	UserStatusUndeclared    UserStatus = 0
+	UserStatusActive        UserStatus = 1
+	UserStatusSuspended     UserStatus = 2
+	UserStatusTerminated    UserStatus = 3
+	UserStatusLimitedAccess UserStatus = 4
+	userStatusMax                      = UserStatusLimitedAccess
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate returns every field Apple would reject, each matching ErrInvalidConsumptionRequest.
+func (c *ConsumptionRequest) Validate() error {
+	var errs []error
+	invalid := func(field, format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s %s", ErrInvalidConsumptionRequest, field, fmt.Sprintf(format, a...)))
+	}
+	inRange := func(field string, v, max int) {
+		if v < 0 || v > max {
+			invalid(field, "%d is not between 0 and %d", v, max)
+		}
+	}
+
+	if !c.CustomerConsented {
+		invalid("customerConsented", "must be true, consumption data is sent only with the customer's consent")
+	}
+	if c.AppAccountToken != "" && !uuidPattern.MatchString(c.AppAccountToken) {
+		invalid("appAccountToken", "%q is not a UUID", c.AppAccountToken)
+	}
+	inRange("accountTenure", int(c.AccountTenure), int(accountTenureMax))
+	inRange("consumptionStatus", int(c.ConsumptionStatus), int(consumptionStatusMax))
+	inRange("deliveryStatus", int(c.DeliveryStatus), int(deliveryStatusMax))
+	inRange("lifetimeDollarsPurchased", int(c.LifetimeDollarsPurchased), int(lifetimeDollarsMax))
+	inRange("lifetimeDollarsRefunded", int(c.LifetimeDollarsRefunded), int(lifetimeDollarsMax))
+	inRange("platform", int(c.Platform), int(platformMax))
+	inRange("playTime", int(c.PlayTime), int(playTimeMax))
+	inRange("refundPreference", int(c.RefundPreference), int(refundPreferenceMax))
+	inRange("userStatus", int(c.UserStatus), int(userStatusMax))
+	return errors.Join(errs...)
+}
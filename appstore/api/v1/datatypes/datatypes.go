@@ -3,6 +3,8 @@ package datatypes
 
 import (
 	"encoding/json"
+	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -24,8 +26,9 @@ const (
 type Environment string
 
 const (
-	Sandbox    Environment = "Sandbox"
-	Production Environment = "Production"
+	Sandbox      Environment = "Sandbox"
+	Production   Environment = "Production"
+	LocalTesting Environment = "LocalTesting"
 )
 
 // InAppOwnershipType see https://developer.apple.com/documentation/appstoreserverapi/inappownershiptype
@@ -98,6 +101,26 @@ func (j *JWSTransactionDecodedPayload) GetSignedTime() time.Time {
 	return time.Unix(j.SignedDate/1e3, 0)
 }
 
+// LogValue implements slog.LogValuer, fields keep their JSON names so identifiers can be redacted by key.
+func (j JWSTransactionDecodedPayload) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("transactionId", j.TransactionID),
+		slog.String("originalTransactionId", j.OriginalTransactionID),
+		slog.String("webOrderLineItemId", j.WebOrderLineItemID),
+		slog.String("appAccountToken", j.AppAccountToken),
+		slog.String("bundleId", j.BundleID),
+		slog.String("productId", j.ProductID),
+		slog.String("type", string(j.Type)),
+		slog.String("environment", string(j.Environment)),
+		slog.String("inAppOwnershipType", string(j.InAppOwnershipType)),
+		slog.Int64("purchaseDate", j.PurchaseDate),
+		slog.Int64("expiresDate", j.ExpiresDate),
+		slog.Int("quantity", j.Quantity),
+		slog.Int64("revocationDate", j.RevocationDate),
+		slog.String("storefront", j.Storefront),
+	)
+}
+
 // JWSRenewalInfo see https://developer.apple.com/documentation/appstoreserverapi/jwsrenewalinfo
 type JWSRenewalInfo struct {
 	Header    JWSDecodedHeader
@@ -146,6 +169,21 @@ func (j *JWSRenewalInfoDecodedPayload) IsConsentedPriceIncrease() bool {
 	return j.PriceIncreaseStatus == 1
 }
 
+// AppTransaction see https://developer.apple.com/documentation/storekit/apptransaction
+type AppTransaction struct {
+	ReceiptType                Environment `json:"receiptType,omitempty"`
+	AppAppleID                 int64       `json:"appAppleId,omitempty"`
+	BundleID                   string      `json:"bundleId,omitempty"`
+	ApplicationVersion         string      `json:"applicationVersion,omitempty"`
+	VersionExternalIdentifier  int64       `json:"versionExternalIdentifier,omitempty"`
+	ReceiptCreationDate        int64       `json:"receiptCreationDate,omitempty"`
+	OriginalPurchaseDate       int64       `json:"originalPurchaseDate,omitempty"`
+	OriginalApplicationVersion string      `json:"originalApplicationVersion,omitempty"`
+	DeviceVerification         string      `json:"deviceVerification,omitempty"`
+	DeviceVerificationNonce    string      `json:"deviceVerificationNonce,omitempty"`
+	PreorderDate               int64       `json:"preorderDate,omitempty"`
+}
+
 // TransactionInfoResponse see https://developer.apple.com/documentation/appstoreserverapi/transactioninforesponse
 type TransactionInfoResponse struct {
 	SignedTransactionInfo string `json:"signedTransactionInfo"`
@@ -214,28 +252,41 @@ type ErrorResponse struct {
 	HTTPStatus   int    `json:"httpStatus,omitempty"`
 	ErrorCode    int64  `json:"errorCode,omitempty"`
 	ErrorMessage string `json:"errorMessage,omitempty"`
+	// Body is the raw response body when it isn't an Apple error, e.g. an HTML page from a proxy.
+	Body []byte `json:"-"`
 }
 
+// Error codes of ErrorResponse, the HTTP status is the code divided by 10000.
+const (
+	ErrorCodeGeneralBadRequest             int64 = 4000000
+	ErrorCodeInvalidRequestRevision        int64 = 4000005
+	ErrorCodeInvalidTransactionID          int64 = 4000006
+	ErrorCodeInvalidPaginationToken        int64 = 4000014
+	ErrorCodeAccountNotFound               int64 = 4040001
+	ErrorCodeOriginalTransactionIDNotFound int64 = 4040005
+	ErrorCodeTestNotificationNotFound      int64 = 4040008
+	ErrorCodeTransactionIDNotFound         int64 = 4040010
+	ErrorCodeRateLimitExceeded             int64 = 4290000
+	ErrorCodeGeneralInternal               int64 = 5000000
+	ErrorCodeGeneralInternalRetryable      int64 = 5000001
+)
+
+// maxErrorBody bounds the part of Body included in Error.
+const maxErrorBody = 512
+
 func (e *ErrorResponse) Error() string {
 	if e == nil {
 		return ""
 	}
 	data, _ := json.Marshal(e)
-	return string(data)
-}
-
-// ConsumptionRequest see https://developer.apple.com/documentation/appstoreserverapi/consumptionrequest
-type ConsumptionRequest struct {
-	AccountTenure            int    `json:"accountTenure,omitempty"`
-	AppAccountToken          string `json:"appAccountToken,omitempty"`
-	ConsumptionStatus        int    `json:"consumptionStatus,omitempty"`
-	CustomerConsented        bool   `json:"customerConsented,omitempty"`
-	DeliveryStatus           int    `json:"deliveryStatus,omitempty"`
-	LifetimeDollarsPurchased int    `json:"lifetimeDollarsPurchased,omitempty"`
-	Platform                 int    `json:"platform,omitempty"`
-	PlayTime                 int    `json:"playTime,omitempty"`
-	SampleContentProvided    bool   `json:"sampleContentProvided,omitempty"`
-	UserStatus               int    `json:"userStatus,omitempty"`
+	if len(e.Body) == 0 {
+		return string(data)
+	}
+	body := e.Body
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return string(data) + ": " + strings.TrimSpace(string(body))
 }
 
 // NotificationHistoryRequest see https://developer.apple.com/documentation/appstoreserverapi/notificationhistoryrequest
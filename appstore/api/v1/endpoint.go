@@ -0,0 +1,88 @@
+package appstoreapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// Endpoint identifies an App Store Server API endpoint, used to apply per-endpoint settings.
+// see https://developer.apple.com/documentation/appstoreserverapi/identifying_rate_limits
+type Endpoint string
+
+const (
+	EndpointTransactionInfo           Endpoint = "transactions"
+	EndpointTransactionHistory        Endpoint = "history"
+	EndpointAllSubscriptionStatuses   Endpoint = "subscriptions"
+	EndpointLookUpOrderID             Endpoint = "lookup"
+	EndpointRefundHistory             Endpoint = "refund"
+	EndpointSendConsumptionInfo       Endpoint = "consumption"
+	EndpointTestNotification          Endpoint = "notifications/test"
+	EndpointGetTestNotificationStatus Endpoint = "notifications/test/status"
+	EndpointNotificationHistory       Endpoint = "notifications/history"
+)
+
+var endpointOperations = map[Endpoint]string{
+	EndpointTransactionInfo:           "TransactionInfo",
+	EndpointTransactionHistory:        "TransactionHistory",
+	EndpointAllSubscriptionStatuses:   "AllSubscriptionStatuses",
+	EndpointLookUpOrderID:             "LookUpOrderID",
+	EndpointRefundHistory:             "RefundHistory",
+	EndpointSendConsumptionInfo:       "SendConsumptionInformation",
+	EndpointTestNotification:          "TestNotification",
+	EndpointGetTestNotificationStatus: "GetTestNotificationStatus",
+	EndpointNotificationHistory:       "NotificationHistory",
+}
+
+// endpointResponse describes the successful responses of an endpoint, any 2xx status is a success.
+type endpointResponse struct {
+	bodyStatuses []int // statuses answered with a body to decode, the other 2xx have none
+}
+
+var okResponse = endpointResponse{bodyStatuses: []int{http.StatusOK}}
+
+var endpointResponses = map[Endpoint]endpointResponse{
+	EndpointTransactionInfo:           okResponse,
+	EndpointTransactionHistory:        okResponse,
+	EndpointAllSubscriptionStatuses:   okResponse,
+	EndpointLookUpOrderID:             okResponse,
+	EndpointRefundHistory:             okResponse,
+	EndpointSendConsumptionInfo:       {}, // 202 Accepted, without a body
+	EndpointTestNotification:          okResponse,
+	EndpointGetTestNotificationStatus: okResponse,
+	EndpointNotificationHistory:       okResponse,
+}
+
+// response returns the successful responses of e, a 200 with a body when e is unknown.
+func (e Endpoint) response() endpointResponse {
+	if r, ok := endpointResponses[e]; ok {
+		return r
+	}
+	return okResponse
+}
+
+// hasBody reports whether a response of status has a body to decode.
+func (r endpointResponse) hasBody(status int) bool {
+	for _, s := range r.bodyStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Operation returns the name of the Service method calling e, e.g. "TransactionHistory".
+func (e Endpoint) Operation() string {
+	return endpointOperations[e]
+}
+
+type endpointKey struct{}
+
+func withEndpoint(ctx context.Context, e Endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey{}, e)
+}
+
+// EndpointFromContext returns the endpoint a request made through Service is addressed to.
+func EndpointFromContext(ctx context.Context) (Endpoint, bool) {
+	e, ok := ctx.Value(endpointKey{}).(Endpoint)
+	return e, ok
+}
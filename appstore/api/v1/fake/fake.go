@@ -0,0 +1,314 @@
+// Package fake provides an in-memory implementation of appstoreapi.AppStoreAPI for tests.
+//
+//	c := fake.New()
+//	c.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1", OriginalTransactionID: "1"})
+//	c.FailWith(appstoreapi.EndpointTransactionHistory, fake.Error(datatypes.ErrorCodeRateLimitExceeded))
+//	svc := NewBillingService(c) // accepts an appstoreapi.AppStoreAPI
+package fake
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	"github.com/gh73962/appleapis/appstore/api/v1/internal/store"
+)
+
+var _ appstoreapi.AppStoreAPI = (*Client)(nil)
+
+// notificationPageSize is the number of notifications returned per NotificationHistory page.
+const notificationPageSize = 20
+
+// Client keeps transactions, subscription statuses and notifications in memory and answers
+// like the App Store Server API. Signed data is encoded without a signature, it can be read
+// with appstoreapi.DecodeToJWSTransaction and appstoreapi.DecodeToJWSRenewalInfo.
+type Client struct {
+	BundleID    string
+	Environment datatypes.Environment
+
+	store store.Store
+
+	mu        sync.Mutex
+	next      map[appstoreapi.Endpoint][]error // queued by FailNext, used before failures
+	failures  map[appstoreapi.Endpoint]error   // set by FailWith
+	testCount int
+}
+
+// New returns an empty Client for the sandbox environment.
+func New() *Client {
+	return &Client{
+		BundleID:    "com.example.app",
+		Environment: datatypes.Sandbox,
+		next:        make(map[appstoreapi.Endpoint][]error),
+		failures:    make(map[appstoreapi.Endpoint]error),
+	}
+}
+
+// Error returns the error the API answers with for code, e.g. datatypes.ErrorCodeTransactionIDNotFound.
+func Error(code int64) *datatypes.ErrorResponse {
+	return &datatypes.ErrorResponse{
+		HTTPStatus: int(code / 10000),
+		ErrorCode:  code,
+	}
+}
+
+// AddTransaction stores t, it is returned by TransactionInfo and in the history of its original transaction.
+func (c *Client) AddTransaction(t datatypes.JWSTransactionDecodedPayload) {
+	c.store.AddTransaction(t)
+}
+
+// AddOrder makes LookUpOrderID return the stored transactions with transactionIDs for orderID.
+func (c *Client) AddOrder(orderID string, transactionIDs ...string) {
+	c.store.AddOrder(orderID, transactionIDs...)
+}
+
+// SetSubscriptionStatus sets the status of the subscription group groupID, its latest transaction t is stored too.
+func (c *Client) SetSubscriptionStatus(groupID string, status datatypes.SubscriptionStatus,
+	t datatypes.JWSTransactionDecodedPayload, renewal datatypes.JWSRenewalInfoDecodedPayload) {
+	c.store.SetSubscriptionStatus(groupID, status, t, renewal)
+}
+
+// AddNotification appends item to the notification history.
+func (c *Client) AddNotification(item datatypes.NotificationHistoryResponseItem) {
+	c.store.AddNotification(store.Notification{SignedPayload: item.SignedPayload, Attempts: item.SendAttempts})
+}
+
+// FailWith makes every call to endpoint fail with err until Heal is called, see Error. It replaces the
+// previous FailWith, the failures queued by FailNext come first.
+func (c *Client) FailWith(endpoint appstoreapi.Endpoint, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[endpoint] = err
+}
+
+// FailNext makes the next call to endpoint fail with err, calls queue in order.
+func (c *Client) FailNext(endpoint appstoreapi.Endpoint, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next[endpoint] = append(c.next[endpoint], err)
+}
+
+// Heal removes the failures of endpoint, queued ones included.
+func (c *Client) Heal(endpoint appstoreapi.Endpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.next, endpoint)
+	delete(c.failures, endpoint)
+}
+
+// Calls returns the number of calls made to endpoint, failed ones included.
+func (c *Client) Calls(endpoint appstoreapi.Endpoint) int {
+	return c.store.Calls(endpoint)
+}
+
+// Consumption returns the last consumption information sent for transactionID.
+func (c *Client) Consumption(transactionID string) (datatypes.ConsumptionRequest, bool) {
+	return c.store.Consumption(transactionID)
+}
+
+// begin records a call to endpoint and returns its injected failure.
+func (c *Client) begin(ctx context.Context, endpoint appstoreapi.Endpoint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.store.Count(endpoint)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if next := c.next[endpoint]; len(next) > 0 {
+		c.next[endpoint] = next[1:]
+		return next[0]
+	}
+	return c.failures[endpoint]
+}
+
+// TransactionInfo see appstoreapi.Service.TransactionInfo
+func (c *Client) TransactionInfo(ctx context.Context, _, transactionID string, _ ...appstoreapi.CallOption) (*datatypes.JWSTransaction, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointTransactionInfo); err != nil {
+		return nil, err
+	}
+	t, ok := c.store.Transaction(transactionID)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+	return appstoreapi.DecodeToJWSTransaction(sign(t))
+}
+
+// TransactionHistory see appstoreapi.Service.TransactionHistory
+func (c *Client) TransactionHistory(ctx context.Context, _, transactionID string, _ ...appstoreapi.CallOption) (*datatypes.HistoryResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointTransactionHistory); err != nil {
+		return nil, err
+	}
+	history, ok := c.store.History(transactionID, nil)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+
+	rsp := datatypes.HistoryResponse{BundleID: c.BundleID, Environment: c.Environment}
+	for _, h := range history {
+		rsp.SignedTransactions = append(rsp.SignedTransactions, sign(h))
+	}
+	return &rsp, nil
+}
+
+// AllSubscriptionStatuses see appstoreapi.Service.AllSubscriptionStatuses
+func (c *Client) AllSubscriptionStatuses(ctx context.Context, _, transactionID string,
+	status datatypes.SubscriptionStatus, _ ...appstoreapi.CallOption) (*datatypes.StatusResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointAllSubscriptionStatuses); err != nil {
+		return nil, err
+	}
+	var statuses []datatypes.SubscriptionStatus
+	if status > 0 {
+		statuses = append(statuses, status)
+	}
+	groups, ok := c.store.Statuses(transactionID, statuses...)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+
+	rsp := datatypes.StatusResponse{BundleID: c.BundleID, Environment: string(c.Environment)}
+	for _, g := range groups {
+		rsp.Data = append(rsp.Data, datatypes.SubscriptionGroupIdentifierItem{
+			SubscriptionGroupIdentifier: g.ID,
+			LastTransactions: []datatypes.LastTransactionsItem{{
+				OriginalTransactionID: g.Transaction.OriginalTransactionID,
+				Status:                g.Status,
+				SignedRenewalInfo:     sign(g.Renewal),
+				SignedTransactionInfo: sign(g.Transaction),
+			}},
+		})
+	}
+	return &rsp, nil
+}
+
+// LookUpOrderID see appstoreapi.Service.LookUpOrderID
+func (c *Client) LookUpOrderID(ctx context.Context, _, orderID string, _ ...appstoreapi.CallOption) (*datatypes.OrderLookupResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointLookUpOrderID); err != nil {
+		return nil, err
+	}
+	order, ok := c.store.Order(orderID)
+	if !ok {
+		return &datatypes.OrderLookupResponse{Status: 1}, nil
+	}
+
+	var rsp datatypes.OrderLookupResponse
+	for _, t := range order {
+		rsp.SignedTransactions = append(rsp.SignedTransactions, sign(t))
+	}
+	return &rsp, nil
+}
+
+// RefundHistory see appstoreapi.Service.RefundHistory, every refunded transaction is returned in one page.
+func (c *Client) RefundHistory(ctx context.Context, _, transactionID, _ string, _ ...appstoreapi.CallOption) (*datatypes.RefundHistoryResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointRefundHistory); err != nil {
+		return nil, err
+	}
+	refunded, ok := c.store.History(transactionID, store.Refunded)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+
+	var rsp datatypes.RefundHistoryResponse
+	for _, h := range refunded {
+		rsp.SignedTransactions = append(rsp.SignedTransactions, sign(h))
+	}
+	return &rsp, nil
+}
+
+// SendConsumptionInformation see appstoreapi.Service.SendConsumptionInformation, a valid cr is kept for Consumption.
+func (c *Client) SendConsumptionInformation(ctx context.Context, _, transactionID string,
+	cr *datatypes.ConsumptionRequest, _ ...appstoreapi.CallOption) error {
+	if err := c.begin(ctx, appstoreapi.EndpointSendConsumptionInfo); err != nil {
+		return err
+	}
+	if _, ok := c.store.Transaction(transactionID); !ok {
+		return Error(datatypes.ErrorCodeTransactionIDNotFound)
+	}
+	if cr == nil {
+		return fmt.Errorf("%w: missing", datatypes.ErrInvalidConsumptionRequest)
+	}
+	if err := cr.Validate(); err != nil {
+		return err
+	}
+	c.store.SendConsumption(transactionID, *cr)
+	return nil
+}
+
+// TestNotification see appstoreapi.Service.TestNotification, the notification is delivered at once.
+func (c *Client) TestNotification(ctx context.Context, _ string, _ ...appstoreapi.CallOption) (*datatypes.SendTestNotificationResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointTestNotification); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.testCount++
+	token := "test-" + strconv.Itoa(c.testCount)
+	c.mu.Unlock()
+
+	c.store.AddTestNotification(token, store.Notification{
+		Payload: map[string]any{
+			"notificationType": "TEST",
+			"notificationUUID": token,
+			"data":             map[string]any{"bundleId": c.BundleID, "environment": c.Environment},
+		},
+		Attempts: []datatypes.SendAttemptItem{{SendAttemptResult: "SUCCESS"}},
+	})
+	return &datatypes.SendTestNotificationResponse{TestNotificationToken: token}, nil
+}
+
+// GetTestNotificationStatus see appstoreapi.Service.GetTestNotificationStatus
+func (c *Client) GetTestNotificationStatus(ctx context.Context, _, testNotificationToken string,
+	_ ...appstoreapi.CallOption) (*datatypes.NotificationHistoryResponseItem, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointGetTestNotificationStatus); err != nil {
+		return nil, err
+	}
+	n, ok := c.store.TestNotification(testNotificationToken)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeTestNotificationNotFound)
+	}
+	item := notificationItem(n)
+	return &item, nil
+}
+
+// NotificationHistory see appstoreapi.Service.NotificationHistory, notifications are returned in the order
+// they were added, nhr filters on OnlyFailures and on the date of the first send attempt.
+func (c *Client) NotificationHistory(ctx context.Context, _, paginationToken string,
+	nhr *datatypes.NotificationHistoryRequest, _ ...appstoreapi.CallOption) (*datatypes.NotificationHistoryResponse, error) {
+	if err := c.begin(ctx, appstoreapi.EndpointNotificationHistory); err != nil {
+		return nil, err
+	}
+
+	matched := c.store.Notifications(nhr)
+	start, end, ok := store.Page(paginationToken, len(matched), notificationPageSize)
+	if !ok {
+		return nil, Error(datatypes.ErrorCodeInvalidPaginationToken)
+	}
+
+	var rsp datatypes.NotificationHistoryResponse
+	for _, n := range matched[start:end] {
+		rsp.NotificationHistory = append(rsp.NotificationHistory, notificationItem(n))
+	}
+	if end < len(matched) {
+		rsp.HasMore = true
+		rsp.PaginationToken = strconv.Itoa(end)
+	}
+	return &rsp, nil
+}
+
+func notificationItem(n store.Notification) datatypes.NotificationHistoryResponseItem {
+	signed := n.SignedPayload
+	if signed == "" {
+		signed = sign(n.Payload)
+	}
+	return datatypes.NotificationHistoryResponseItem{SignedPayload: signed, SendAttempts: n.Attempts}
+}
+
+// sign encodes v as unsigned JWS compact serialization, the way appstoreapi.DecodeSignedData reads it.
+func sign(v any) string {
+	payload, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "."
+}
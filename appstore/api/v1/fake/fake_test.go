@@ -0,0 +1,159 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestClientSeeded(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+	c.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1", ProductID: "monthly", PurchaseDate: 1})
+	c.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1", PurchaseDate: 2, RevocationDate: 3})
+	c.SetSubscriptionStatus("group", datatypes.Active,
+		datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1", PurchaseDate: 2, RevocationDate: 3},
+		datatypes.JWSRenewalInfoDecodedPayload{AutoRenewProductID: "monthly"})
+	c.AddOrder("ORDER1", "1")
+
+	info, err := c.TransactionInfo(ctx, "", "1")
+	if err != nil || info.Payload.ProductID != "monthly" {
+		t.Errorf("TransactionInfo() = %+v, %v", info, err)
+	}
+
+	history, err := c.TransactionHistory(ctx, "", "2")
+	if err != nil || len(history.SignedTransactions) != 2 {
+		t.Fatalf("TransactionHistory() = %+v, %v", history, err)
+	}
+	last, err := appstoreapi.DecodeToJWSTransaction(history.SignedTransactions[1])
+	if err != nil || last.Payload.TransactionID != "2" {
+		t.Errorf("TransactionHistory() last = %+v, %v", last, err)
+	}
+
+	status, err := c.AllSubscriptionStatuses(ctx, "", "1", datatypes.Active)
+	if err != nil || len(status.Data) != 1 || status.Data[0].LastTransactions[0].Status != datatypes.Active {
+		t.Fatalf("AllSubscriptionStatuses() = %+v, %v", status, err)
+	}
+	renewal, err := appstoreapi.DecodeToJWSRenewalInfo(status.Data[0].LastTransactions[0].SignedRenewalInfo)
+	if err != nil || renewal.Payload.AutoRenewProductID != "monthly" {
+		t.Errorf("AllSubscriptionStatuses() renewal = %+v, %v", renewal, err)
+	}
+	if status, _ := c.AllSubscriptionStatuses(ctx, "", "1", datatypes.Expired); len(status.Data) != 0 {
+		t.Errorf("AllSubscriptionStatuses(Expired) = %+v, want none", status)
+	}
+
+	if order, err := c.LookUpOrderID(ctx, "", "ORDER1"); err != nil || !order.IsValid() || len(order.SignedTransactions) != 1 {
+		t.Errorf("LookUpOrderID() = %+v, %v", order, err)
+	}
+	if order, err := c.LookUpOrderID(ctx, "", "UNKNOWN"); err != nil || order.IsValid() {
+		t.Errorf("LookUpOrderID(unknown) = %+v, %v", order, err)
+	}
+	if refunds, err := c.RefundHistory(ctx, "", "1", ""); err != nil || len(refunds.SignedTransactions) != 1 {
+		t.Errorf("RefundHistory() = %+v, %v", refunds, err)
+	}
+
+	if err := c.SendConsumptionInformation(ctx, "", "1", &datatypes.ConsumptionRequest{CustomerConsented: true, PlayTime: 3}); err != nil {
+		t.Errorf("SendConsumptionInformation() error = %v", err)
+	}
+	if cr, ok := c.Consumption("1"); !ok || cr.PlayTime != 3 {
+		t.Errorf("Consumption() = %+v, %v", cr, ok)
+	}
+
+	test, err := c.TestNotification(ctx, "")
+	if err != nil {
+		t.Fatalf("TestNotification() error = %v", err)
+	}
+	if _, err := c.GetTestNotificationStatus(ctx, "", test.TestNotificationToken); err != nil {
+		t.Errorf("GetTestNotificationStatus() error = %v", err)
+	}
+}
+
+func TestClientNotificationHistory(t *testing.T) {
+	c := New()
+	for i := 0; i < notificationPageSize+5; i++ {
+		result := "SUCCESS"
+		if i%5 == 0 {
+			result = "TIMED_OUT"
+		}
+		c.AddNotification(datatypes.NotificationHistoryResponseItem{
+			SignedPayload: strconv.Itoa(i),
+			SendAttempts:  []datatypes.SendAttemptItem{{SendAttemptResult: result}},
+		})
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		req       *datatypes.NotificationHistoryRequest
+		wantItems int
+		wantMore  bool
+	}{
+		{name: "first page", wantItems: notificationPageSize, wantMore: true},
+		{name: "last page", token: strconv.Itoa(notificationPageSize), wantItems: 5},
+		{name: "only failures", req: &datatypes.NotificationHistoryRequest{OnlyFailures: true}, wantItems: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.NotificationHistory(context.Background(), "", tt.token, tt.req)
+			if err != nil {
+				t.Fatalf("NotificationHistory() error = %v", err)
+			}
+			if len(rsp.NotificationHistory) != tt.wantItems || rsp.HasMore != tt.wantMore {
+				t.Errorf("NotificationHistory() = %d items, hasMore %v, want %d, %v",
+					len(rsp.NotificationHistory), rsp.HasMore, tt.wantItems, tt.wantMore)
+			}
+		})
+	}
+}
+
+func TestClientFailures(t *testing.T) {
+	ctx := context.Background()
+	c := New()
+	c.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1"})
+
+	c.FailNext(appstoreapi.EndpointTransactionInfo, Error(datatypes.ErrorCodeRateLimitExceeded))
+	_, err := c.TransactionInfo(ctx, "", "1")
+	var errResp *datatypes.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.ErrorCode != datatypes.ErrorCodeRateLimitExceeded || errResp.HTTPStatus != 429 {
+		t.Errorf("TransactionInfo() error = %v, want rate limit", err)
+	}
+	if !appstoreapi.IsRetryable(nil, err) {
+		t.Errorf("IsRetryable(%v) = false", err)
+	}
+	if _, err := c.TransactionInfo(ctx, "", "1"); err != nil {
+		t.Errorf("TransactionInfo() after FailNext error = %v", err)
+	}
+
+	c.FailWith(appstoreapi.EndpointTransactionInfo, Error(datatypes.ErrorCodeGeneralInternal))
+	for i := 0; i < 2; i++ {
+		if _, err := c.TransactionInfo(ctx, "", "1"); err == nil {
+			t.Errorf("TransactionInfo() error = nil, want internal error")
+		}
+	}
+	c.Heal(appstoreapi.EndpointTransactionInfo)
+	if _, err := c.TransactionInfo(ctx, "", "1"); err != nil {
+		t.Errorf("TransactionInfo() after Heal error = %v", err)
+	}
+	if got := c.Calls(appstoreapi.EndpointTransactionInfo); got != 5 {
+		t.Errorf("Calls() = %d, want 5", got)
+	}
+
+	c.FailWith(appstoreapi.EndpointTransactionInfo, Error(datatypes.ErrorCodeGeneralInternal))
+	c.FailNext(appstoreapi.EndpointTransactionInfo, Error(datatypes.ErrorCodeRateLimitExceeded))
+	for _, want := range []int64{datatypes.ErrorCodeRateLimitExceeded, datatypes.ErrorCodeGeneralInternal} {
+		_, err := c.TransactionInfo(ctx, "", "1")
+		if !errors.As(err, &errResp) || errResp.ErrorCode != want {
+			t.Errorf("TransactionInfo() error = %v, want %d, FailNext before FailWith", err, want)
+		}
+	}
+	c.Heal(appstoreapi.EndpointTransactionInfo)
+
+	_, err = c.TransactionInfo(ctx, "", "404")
+	if !errors.As(err, &errResp) || errResp.ErrorCode != datatypes.ErrorCodeTransactionIDNotFound {
+		t.Errorf("TransactionInfo(unknown) error = %v", err)
+	}
+}
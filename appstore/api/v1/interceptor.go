@@ -0,0 +1,70 @@
+package appstoreapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// CallInfo describes the call or attempt an Interceptor is wrapping.
+type CallInfo struct {
+	Operation   string // Service method, e.g. "TransactionHistory", empty for requests sent directly with Do
+	Endpoint    Endpoint
+	Environment datatypes.Environment
+	Attempt     int           // starting at 1, for call interceptors the number of attempts made once next returns
+	Pause       time.Duration // backoff or Retry-After waited before this attempt
+}
+
+// Invoker sends one attempt of a request, non 2xx responses are returned with a *datatypes.ErrorResponse error.
+type Invoker func(req *http.Request) (*http.Response, error)
+
+// Interceptor wraps every attempt made by Service.Do, or the whole call with all its retries when
+// added by WithCallInterceptors. It must call next to continue the chain.
+// Interceptors run in the order they were added, attempt interceptors outside of the circuit breaker and rate limiter.
+type Interceptor func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error)
+
+// intercept sends an attempt of req through the attempt interceptor chain.
+func (s *Service) intercept(req *http.Request, info CallInfo) (*http.Response, error) {
+	return chain(s.interceptors, &info, s.attempt)(req)
+}
+
+func chain(interceptors []Interceptor, info *CallInfo, last Invoker) Invoker {
+	next := last
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, invoke := interceptors[i], next
+		next = func(req *http.Request) (*http.Response, error) {
+			return interceptor(info, req, invoke)
+		}
+	}
+	return next
+}
+
+// LoggingInterceptor logs the outcome of every attempt with logf, e.g. LoggingInterceptor(log.Printf).
+// Paths are not logged because they carry transaction identifiers.
+func LoggingInterceptor(logf func(format string, v ...any)) Interceptor {
+	return func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		var status int
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		if err != nil {
+			logf("appstoreapi: %s %s attempt %d status %d in %v: %v", info.Operation, req.Method, info.Attempt, status, time.Since(start), err)
+		} else {
+			logf("appstoreapi: %s %s attempt %d status %d in %v", info.Operation, req.Method, info.Attempt, status, time.Since(start))
+		}
+		return resp, err
+	}
+}
+
+// TimingInterceptor reports how long every attempt took to observe.
+func TimingInterceptor(observe func(info CallInfo, elapsed time.Duration, err error)) Interceptor {
+	return func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+		observe(*info, time.Since(start), err)
+		return resp, err
+	}
+}
@@ -0,0 +1,63 @@
+package appstoreapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServiceInterceptors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-ID") == "" {
+			t.Errorf("X-Request-ID header not set by interceptor")
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var got []string
+	record := func(name string) Interceptor {
+		return func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+			resp, err := next(req)
+			got = append(got, fmt.Sprintf("%s %s attempt %d err %t", name, info.Operation, info.Attempt, err != nil))
+			return resp, err
+		}
+	}
+	requestID := func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Request-ID", fmt.Sprint(info.Attempt))
+		return next(req)
+	}
+
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithRetry(time.Millisecond, time.Millisecond),
+		WithInterceptors(record("outer"), requestID),
+		WithInterceptors(record("inner")),
+	)
+	s.BasePath = srv.URL + "/"
+
+	if _, err := s.TransactionHistory(context.Background(), "bearer", "1"); err != nil {
+		t.Fatalf("TransactionHistory() error = %v", err)
+	}
+
+	want := []string{
+		"inner TransactionHistory attempt 1 err true",
+		"outer TransactionHistory attempt 1 err true",
+		"inner TransactionHistory attempt 2 err false",
+		"outer TransactionHistory attempt 2 err false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("interceptors got = %v, want %v", got, want)
+	}
+}
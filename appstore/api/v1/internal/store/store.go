@@ -0,0 +1,279 @@
+// Package store keeps the in-memory state of the App Store Server API shared by the fake client and the
+// appstoretest emulator: transactions, orders, subscription groups, notifications, consumption information and
+// the count of calls per endpoint. It is safe for concurrent use.
+package store
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// Group is the latest state of a subscription group of an original transaction.
+type Group struct {
+	ID          string
+	Status      datatypes.SubscriptionStatus
+	Transaction datatypes.JWSTransactionDecodedPayload
+	Renewal     datatypes.JWSRenewalInfoDecodedPayload
+}
+
+// Notification is an entry of the notification history, Payload is signed when answered unless SignedPayload is set.
+type Notification struct {
+	Payload       any
+	SignedPayload string
+	Attempts      []datatypes.SendAttemptItem
+}
+
+// Store is the state of an emulated API, the zero value is empty and ready to use.
+type Store struct {
+	mu                sync.Mutex
+	transactions      map[string]datatypes.JWSTransactionDecodedPayload
+	orders            map[string][]string
+	groups            map[string][]Group // by original transaction ID
+	notifications     []Notification
+	testNotifications map[string]Notification
+	consumption       map[string]datatypes.ConsumptionRequest
+	calls             map[appstoreapi.Endpoint]int
+}
+
+// AddTransaction stores t, its original transaction is itself when not set. The stored transaction is returned.
+func (s *Store) AddTransaction(t datatypes.JWSTransactionDecodedPayload) datatypes.JWSTransactionDecodedPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addTransaction(t)
+}
+
+func (s *Store) addTransaction(t datatypes.JWSTransactionDecodedPayload) datatypes.JWSTransactionDecodedPayload {
+	if t.OriginalTransactionID == "" {
+		t.OriginalTransactionID = t.TransactionID
+	}
+	if s.transactions == nil {
+		s.transactions = make(map[string]datatypes.JWSTransactionDecodedPayload)
+	}
+	s.transactions[t.TransactionID] = t
+	return t
+}
+
+// Transaction returns the transaction transactionID.
+func (s *Store) Transaction(transactionID string) (datatypes.JWSTransactionDecodedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[transactionID]
+	return t, ok
+}
+
+// AddOrder makes Order return the transactions with transactionIDs for orderID.
+func (s *Store) AddOrder(orderID string, transactionIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.orders == nil {
+		s.orders = make(map[string][]string)
+	}
+	s.orders[orderID] = append(s.orders[orderID], transactionIDs...)
+}
+
+// Order returns the stored transactions of orderID, false when the order is unknown.
+func (s *Store) Order(orderID string) ([]datatypes.JWSTransactionDecodedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids, ok := s.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	var order []datatypes.JWSTransactionDecodedPayload
+	for _, id := range ids {
+		if t, ok := s.transactions[id]; ok {
+			order = append(order, t)
+		}
+	}
+	return order, true
+}
+
+// SetSubscriptionStatus sets the status of the subscription group groupID, its latest transaction t is stored too.
+func (s *Store) SetSubscriptionStatus(groupID string, status datatypes.SubscriptionStatus,
+	t datatypes.JWSTransactionDecodedPayload, renewal datatypes.JWSRenewalInfoDecodedPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t = s.addTransaction(t)
+	if renewal.OriginalTransactionID == "" {
+		renewal.OriginalTransactionID = t.OriginalTransactionID
+	}
+	g := Group{ID: groupID, Status: status, Transaction: t, Renewal: renewal}
+	if s.groups == nil {
+		s.groups = make(map[string][]Group)
+	}
+	groups := s.groups[t.OriginalTransactionID]
+	for i := range groups {
+		if groups[i].ID == groupID {
+			groups[i] = g
+			return
+		}
+	}
+	s.groups[t.OriginalTransactionID] = append(groups, g)
+}
+
+// Statuses returns the subscription groups of the original transaction of transactionID, filtered by statuses
+// when not empty. It returns false when transactionID is unknown.
+func (s *Store) Statuses(transactionID string, statuses ...datatypes.SubscriptionStatus) ([]Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[transactionID]
+	if !ok {
+		return nil, false
+	}
+	var groups []Group
+	for _, g := range s.groups[t.OriginalTransactionID] {
+		if len(statuses) == 0 || hasStatus(statuses, g.Status) {
+			groups = append(groups, g)
+		}
+	}
+	return groups, true
+}
+
+func hasStatus(statuses []datatypes.SubscriptionStatus, status datatypes.SubscriptionStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns the transactions of the original transaction of transactionID accepted by keep, by purchase
+// date, every one when keep is nil. It returns false when transactionID is unknown.
+func (s *Store) History(transactionID string, keep func(datatypes.JWSTransactionDecodedPayload) bool) ([]datatypes.JWSTransactionDecodedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.transactions[transactionID]
+	if !ok {
+		return nil, false
+	}
+	var history []datatypes.JWSTransactionDecodedPayload
+	for _, h := range s.transactions {
+		if h.OriginalTransactionID == t.OriginalTransactionID && (keep == nil || keep(h)) {
+			history = append(history, h)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].PurchaseDate != history[j].PurchaseDate {
+			return history[i].PurchaseDate < history[j].PurchaseDate
+		}
+		return history[i].TransactionID < history[j].TransactionID
+	})
+	return history, true
+}
+
+// Refunded keeps the refunded or revoked transactions, see History.
+func Refunded(t datatypes.JWSTransactionDecodedPayload) bool {
+	return t.RevocationDate != 0
+}
+
+// SendConsumption keeps cr as the last consumption information of transactionID, false when it is unknown.
+func (s *Store) SendConsumption(transactionID string, cr datatypes.ConsumptionRequest) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.transactions[transactionID]; !ok {
+		return false
+	}
+	if s.consumption == nil {
+		s.consumption = make(map[string]datatypes.ConsumptionRequest)
+	}
+	s.consumption[transactionID] = cr
+	return true
+}
+
+// Consumption returns the last consumption information sent for transactionID.
+func (s *Store) Consumption(transactionID string) (datatypes.ConsumptionRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cr, ok := s.consumption[transactionID]
+	return cr, ok
+}
+
+// AddNotification appends n to the notification history.
+func (s *Store) AddNotification(n Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, n)
+}
+
+// Notifications returns the notification history filtered by req, in the order it was added.
+func (s *Store) Notifications(req *datatypes.NotificationHistoryRequest) []Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []Notification
+	for _, n := range s.notifications {
+		if req == nil || keepNotification(req, n) {
+			matched = append(matched, n)
+		}
+	}
+	return matched
+}
+
+// keepNotification filters n by the failures and the date range of its first attempt requested by req.
+func keepNotification(req *datatypes.NotificationHistoryRequest, n Notification) bool {
+	if req.OnlyFailures {
+		failed := false
+		for _, a := range n.Attempts {
+			failed = failed || a.SendAttemptResult != "SUCCESS"
+		}
+		if !failed {
+			return false
+		}
+	}
+	if len(n.Attempts) == 0 {
+		return true
+	}
+	date := n.Attempts[0].AttemptDate
+	return (req.StartDate == 0 || date >= req.StartDate) && (req.EndDate == 0 || date < req.EndDate)
+}
+
+// AddTestNotification keeps n, the test notification of token.
+func (s *Store) AddTestNotification(token string, n Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.testNotifications == nil {
+		s.testNotifications = make(map[string]Notification)
+	}
+	s.testNotifications[token] = n
+}
+
+// TestNotification returns the test notification of token.
+func (s *Store) TestNotification(token string) (Notification, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.testNotifications[token]
+	return n, ok
+}
+
+// Count records a call to endpoint.
+func (s *Store) Count(endpoint appstoreapi.Endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[appstoreapi.Endpoint]int)
+	}
+	s.calls[endpoint]++
+}
+
+// Calls returns the number of calls recorded for endpoint.
+func (s *Store) Calls(endpoint appstoreapi.Endpoint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[endpoint]
+}
+
+// Page returns the bounds of the page of size among n items starting at token, the token of the next page is end.
+// An empty token is the first page, ok is false for an invalid token.
+func Page(token string, n, size int) (start, end int, ok bool) {
+	if token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil || start < 0 || start > n {
+			return 0, 0, false
+		}
+	}
+	return start, min(start+size, n), true
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestStoreHistory(t *testing.T) {
+	var s Store
+	s.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "1", PurchaseDate: 3})
+	s.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "3", OriginalTransactionID: "1", PurchaseDate: 1, RevocationDate: 5})
+	s.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "2", OriginalTransactionID: "1", PurchaseDate: 1})
+	s.AddTransaction(datatypes.JWSTransactionDecodedPayload{TransactionID: "4", PurchaseDate: 2})
+
+	ids := func(history []datatypes.JWSTransactionDecodedPayload) []string {
+		var ids []string
+		for _, t := range history {
+			ids = append(ids, t.TransactionID)
+		}
+		return ids
+	}
+	if history, ok := s.History("2", nil); !ok || !reflect.DeepEqual(ids(history), []string{"2", "3", "1"}) {
+		t.Errorf("History() = %v, %v", ids(history), ok)
+	}
+	if refunded, ok := s.History("1", Refunded); !ok || !reflect.DeepEqual(ids(refunded), []string{"3"}) {
+		t.Errorf("History(Refunded) = %v, %v", ids(refunded), ok)
+	}
+	if _, ok := s.History("5", nil); ok {
+		t.Error("History() of an unknown transaction ok")
+	}
+}
+
+func TestStoreStatuses(t *testing.T) {
+	var s Store
+	tx := datatypes.JWSTransactionDecodedPayload{TransactionID: "1"}
+	s.SetSubscriptionStatus("a", datatypes.Active, tx, datatypes.JWSRenewalInfoDecodedPayload{})
+	s.SetSubscriptionStatus("b", datatypes.Expired, tx, datatypes.JWSRenewalInfoDecodedPayload{})
+	s.SetSubscriptionStatus("a", datatypes.BillingRetryPeriod, tx, datatypes.JWSRenewalInfoDecodedPayload{})
+
+	groups, ok := s.Statuses("1")
+	if !ok || len(groups) != 2 || groups[0].Status != datatypes.BillingRetryPeriod || groups[0].Renewal.OriginalTransactionID != "1" {
+		t.Errorf("Statuses() = %+v, %v", groups, ok)
+	}
+	if groups, _ := s.Statuses("1", datatypes.Expired); len(groups) != 1 || groups[0].ID != "b" {
+		t.Errorf("Statuses(Expired) = %+v", groups)
+	}
+}
+
+func TestStoreCalls(t *testing.T) {
+	var s Store
+	s.Count(appstoreapi.EndpointTransactionInfo)
+	s.Count(appstoreapi.EndpointTransactionInfo)
+	if got := s.Calls(appstoreapi.EndpointTransactionInfo); got != 2 {
+		t.Errorf("Calls() = %d, want 2", got)
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		token              string
+		n, size            int
+		wantStart, wantEnd int
+		wantOK             bool
+	}{
+		{"", 5, 2, 0, 2, true},
+		{"2", 5, 2, 2, 4, true},
+		{"4", 5, 2, 4, 5, true},
+		{"5", 5, 2, 5, 5, true},
+		{"6", 5, 2, 0, 0, false},
+		{"x", 5, 2, 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := Page(tt.token, tt.n, tt.size)
+		if start != tt.wantStart || end != tt.wantEnd || ok != tt.wantOK {
+			t.Errorf("Page(%q, %d, %d) = %d, %d, %v", tt.token, tt.n, tt.size, start, end, ok)
+		}
+	}
+}
@@ -47,20 +47,36 @@ func DecodeToJWSRenewalInfo(data string) (*datatypes.JWSRenewalInfo, error) {
 	return &t, nil
 }
 
+// DecodeSignedData splits JWS compact serialization data into its decoded header and payload and its signature,
+// the signature isn't verified.
 func DecodeSignedData(data string) ([]byte, []byte, string, error) {
 	array := strings.Split(data, ".")
 	if len(array) != 3 {
 		return nil, nil, "", errors.New("invalid signed data")
 	}
 
-	header, err := base64.RawStdEncoding.DecodeString(array[0])
+	header, err := decodeSegment(array[0])
 	if err != nil {
 		return nil, nil, "", err
 	}
-	payload, err := base64.RawStdEncoding.DecodeString(array[1])
+	payload, err := decodeSegment(array[1])
 	if err != nil {
 		return nil, nil, "", err
 	}
 
 	return header, payload, array[2], nil
 }
+
+// decodeSegment decodes a base64url segment, the encoding of JWS, padded or not. Segments in the standard
+// alphabet, decoded by the earlier versions, are still accepted.
+func decodeSegment(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		return b, nil
+	}
+	if b, stdErr := base64.RawStdEncoding.DecodeString(s); stdErr == nil {
+		return b, nil
+	}
+	return nil, err
+}
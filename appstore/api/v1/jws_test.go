@@ -1,7 +1,9 @@
 package appstoreapi
 
 import (
+	"encoding/base64"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
@@ -114,3 +116,37 @@ func TestDecodeToJWSRenewalInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeSignedData(t *testing.T) {
+	header := []byte(`{"alg":"ES256"}`)
+	payload := []byte(`{"productId":"~~~?"}`) // encodes with both - and _ in base64url
+	url := base64.RawURLEncoding.EncodeToString(payload)
+	if !strings.ContainsAny(url, "-_") {
+		t.Fatalf("payload %s has no base64url specific character", url)
+	}
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"base64url", base64.RawURLEncoding.EncodeToString(header) + "." + url + ".sig", false},
+		{"padded base64url", base64.URLEncoding.EncodeToString(header) + "." + base64.URLEncoding.EncodeToString(payload) + ".sig", false},
+		{"standard alphabet", base64.RawStdEncoding.EncodeToString(header) + "." + base64.RawStdEncoding.EncodeToString(payload) + ".sig", false},
+		{"invalid base64", "e30.!!!.sig", true},
+		{"not 3 parts", "e30.e30", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHeader, gotPayload, sig, err := DecodeSignedData(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeSignedData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(gotHeader) != string(header) || string(gotPayload) != string(payload) || sig != "sig" {
+				t.Errorf("DecodeSignedData() = %s, %s, %s", gotHeader, gotPayload, sig)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package appstoreapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// endpointIDKeys names the identifier at the end of the path of an endpoint, the redacting handler recognizes them.
+var endpointIDKeys = map[Endpoint]string{
+	EndpointTransactionInfo:           "transactionId",
+	EndpointTransactionHistory:        "transactionId",
+	EndpointAllSubscriptionStatuses:   "transactionId",
+	EndpointLookUpOrderID:             "orderId",
+	EndpointRefundHistory:             "transactionId",
+	EndpointSendConsumptionInfo:       "transactionId",
+	EndpointGetTestNotificationStatus: "testNotificationToken",
+}
+
+func logAttrs(info *CallInfo, req *http.Request) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("operation", info.Operation),
+		slog.String("environment", string(info.Environment)),
+		slog.String("method", req.Method),
+	}
+	if key, ok := endpointIDKeys[info.Endpoint]; ok {
+		attrs = append(attrs, slog.String(key, path.Base(req.URL.Path)))
+	}
+	return attrs
+}
+
+func resultLogAttrs(resp *http.Response, err error) []slog.Attr {
+	var attrs []slog.Attr
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	var errResp *datatypes.ErrorResponse
+	if errors.As(err, &errResp) && errResp.ErrorCode != 0 {
+		attrs = append(attrs, slog.Int64("errorCode", errResp.ErrorCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", errorMessage(err)))
+	}
+	return attrs
+}
+
+// errorMessage drops the URL from transport errors, it carries identifiers.
+func errorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Op + ": " + urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// callLogger logs the start and finish of every call to l.
+func callLogger(l *slog.Logger) Interceptor {
+	return func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		ctx := req.Context()
+		l.LogAttrs(ctx, slog.LevelDebug, "appstoreapi: call started", logAttrs(info, req)...)
+
+		start := time.Now()
+		resp, err := next(req)
+
+		attrs := append(logAttrs(info, req),
+			slog.Int("attempts", info.Attempt),
+			slog.Duration("elapsed", time.Since(start)))
+		attrs = append(attrs, resultLogAttrs(resp, err)...)
+		if err != nil {
+			l.LogAttrs(ctx, slog.LevelError, "appstoreapi: call failed", attrs...)
+		} else {
+			l.LogAttrs(ctx, slog.LevelInfo, "appstoreapi: call finished", attrs...)
+		}
+		return resp, err
+	}
+}
+
+// attemptLogger logs retries with their backoff and failed attempts to l.
+func attemptLogger(l *slog.Logger) Interceptor {
+	return func(info *CallInfo, req *http.Request, next Invoker) (*http.Response, error) {
+		ctx := req.Context()
+		if info.Attempt > 1 {
+			l.LogAttrs(ctx, slog.LevelWarn, "appstoreapi: retrying",
+				slog.String("operation", info.Operation),
+				slog.Int("attempt", info.Attempt),
+				slog.Duration("backoff", info.Pause))
+		}
+
+		resp, err := next(req)
+		if err != nil {
+			attrs := append(logAttrs(info, req), slog.Int("attempt", info.Attempt))
+			l.LogAttrs(ctx, slog.LevelDebug, "appstoreapi: attempt failed", append(attrs, resultLogAttrs(resp, err)...)...)
+		}
+		return resp, err
+	}
+}
@@ -2,29 +2,26 @@ package appstoreapi
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
 )
 
 // LookUpOrderID see https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
-func (s *Service) LookUpOrderID(ctx context.Context, bearer, orderID string) (*datatypes.OrderLookupResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, s.BasePath+"lookup/"+orderID, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
+func (s *Service) LookUpOrderID(ctx context.Context, bearer, orderID string, opts ...CallOption) (*datatypes.OrderLookupResponse, error) {
+	if err := validateOrderID(orderID); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
 	var rsp datatypes.OrderLookupResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointLookUpOrderID,
+		method:   http.MethodGet,
+		path:     []string{"lookup", orderID},
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,70 @@
+package appstoreapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// ResponseMeta describes the HTTP exchange behind a call, pass it with ContextWithResponseMeta.
+//
+//	var meta appstoreapi.ResponseMeta
+//	info, err := s.TransactionInfo(appstoreapi.ContextWithResponseMeta(ctx, &meta), bearer, id)
+//	log.Println(meta.StatusCode, meta.RequestID())
+type ResponseMeta struct {
+	CaptureBody bool // set by the caller to keep the raw body of the last response in Body
+
+	StatusCode  int // of the last response, 0 when none was received
+	Header      http.Header
+	Attempts    int
+	Elapsed     time.Duration
+	Environment datatypes.Environment
+	Body        []byte
+}
+
+// requestIDHeaders are checked in order by RequestID.
+var requestIDHeaders = []string{"X-Apple-Request-Uuid", "X-Request-Id", "X-Amzn-Trace-Id"}
+
+// RequestID returns the request tracking header of the response, if any.
+func (m *ResponseMeta) RequestID() string {
+	for _, key := range requestIDHeaders {
+		if v := m.Header.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type responseMetaKey struct{}
+
+// ContextWithResponseMeta makes calls made with ctx fill meta once they return.
+func ContextWithResponseMeta(ctx context.Context, meta *ResponseMeta) context.Context {
+	return context.WithValue(ctx, responseMetaKey{}, meta)
+}
+
+func responseMetaFromContext(ctx context.Context) *ResponseMeta {
+	meta, _ := ctx.Value(responseMetaKey{}).(*ResponseMeta)
+	return meta
+}
+
+// fill records resp in m, the body is read up front when captured and replaced with the buffered copy.
+func (m *ResponseMeta) fill(info *CallInfo, resp *http.Response, elapsed time.Duration) {
+	m.Attempts = info.Attempt
+	m.Elapsed = elapsed
+	m.Environment = info.Environment
+	if resp == nil {
+		return
+	}
+
+	m.StatusCode = resp.StatusCode
+	m.Header = resp.Header
+	if m.CaptureBody && resp.Body != nil {
+		m.Body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(m.Body))
+	}
+}
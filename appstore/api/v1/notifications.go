@@ -1,32 +1,25 @@
 package appstoreapi
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
 )
 
 // TestNotification see https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
-func (s *Service) TestNotification(ctx context.Context, bearer string) (*datatypes.SendTestNotificationResponse, error) {
-	req, err := http.NewRequest(http.MethodPost, s.BasePath+"notifications/test", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (s *Service) TestNotification(ctx context.Context, bearer string, opts ...CallOption) (*datatypes.SendTestNotificationResponse, error) {
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
 	var rsp datatypes.SendTestNotificationResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointTestNotification,
+		method:   http.MethodPost,
+		path:     []string{"notifications", "test"},
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -34,22 +27,20 @@ func (s *Service) TestNotification(ctx context.Context, bearer string) (*datatyp
 }
 
 // GetTestNotificationStatus see https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
-func (s *Service) GetTestNotificationStatus(ctx context.Context, bearer, testNotificationToken string) (*datatypes.NotificationHistoryResponseItem, error) {
-	req, err := http.NewRequest(http.MethodGet, s.BasePath+"notifications/test/"+testNotificationToken, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
+func (s *Service) GetTestNotificationStatus(ctx context.Context, bearer, testNotificationToken string, opts ...CallOption) (*datatypes.NotificationHistoryResponseItem, error) {
+	if err := validateToken("testNotificationToken", testNotificationToken); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
 	var rsp datatypes.NotificationHistoryResponseItem
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointGetTestNotificationStatus,
+		method:   http.MethodGet,
+		path:     []string{"notifications", "test", testNotificationToken},
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -58,34 +49,24 @@ func (s *Service) GetTestNotificationStatus(ctx context.Context, bearer, testNot
 
 // NotificationHistory see https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
 func (s *Service) NotificationHistory(ctx context.Context, bearer, paginationToken string,
-	nhr *datatypes.NotificationHistoryRequest) (*datatypes.NotificationHistoryResponse, error) {
-
-	var buff bytes.Buffer
-	if err := json.NewEncoder(&buff).Encode(nhr); err != nil {
-		return nil, err
-	}
+	nhr *datatypes.NotificationHistoryRequest, opts ...CallOption) (*datatypes.NotificationHistoryResponse, error) {
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
-	u := s.BasePath + "notifications/history"
+	var query url.Values
 	if paginationToken != "" {
-		u += fmt.Sprintf("?paginationToken=%s", paginationToken)
+		query = url.Values{"paginationToken": {paginationToken}}
 	}
 
-	req, err := http.NewRequest(http.MethodPost, u, &buff)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var rsp datatypes.NotificationHistoryResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointNotificationHistory,
+		method:   http.MethodPost,
+		path:     []string{"notifications", "history"},
+		query:    query,
+		body:     nhr,
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -1,7 +1,9 @@
 package appstoreapi
 
 import (
+	"log/slog"
 	"net/http"
+	"net/url"
 	"time"
 
 	appleapigoclient "github.com/gh73962/appleapis"
@@ -12,14 +14,48 @@ import (
 type Option func(*ClientOption)
 
 type ClientOption struct {
-	NeedRetry    bool          // retry use backoff and jitter
-	RetryInitial time.Duration // retry first retry pause duration , default 100ms
-	RetryMax     time.Duration // retry max duration, default 30s
-	HTTPClient   *http.Client  // default use http.DefaultClient
-	UserAgent    string        // default apple-api-go-client
-	IsSandbox    bool          // default false
+	NeedRetry        bool                  // retry use backoff and jitter
+	RetryPolicy      RetryPolicy           // default DefaultRetryPolicy
+	RetryInitial     time.Duration         // retry first retry pause duration , default 100ms
+	RetryMax         time.Duration         // retry max duration, default 30s
+	RetryStrategy    BackoffStrategy       // default BackoffFullJitter
+	Backoff          BackoffFactory        // custom backoff, overrides RetryStrategy
+	HTTPClient       *http.Client          // default a client with the library's own tuned transport, see NewTransport
+	Proxy            *url.URL              // proxy of the default client, default from the environment
+	MaxResponseBytes int64                 // default DefaultMaxResponseBytes, negative for unlimited
+	Timeout          time.Duration         // default of CallTimeout, default none
+	AttemptTimeout   time.Duration         // default of CallAttemptTimeout, default none
+	UserAgent        string                // default apple-api-go-client
+	IsSandbox        bool                  // default false, same as Environment datatypes.Sandbox
+	Environment      datatypes.Environment // default datatypes.Production
+	BaseURL          string                // send every environment to this URL instead, e.g. a local stand-in
+	Resolver         EnvironmentResolver   // default DefaultResolver
+
+	RateLimits        map[Endpoint]RateLimit // client-side token bucket per endpoint, default unlimited
+	RateLimitFailFast bool                   // return ErrRateLimited instead of waiting for a token
+
+	CircuitBreaker  *CircuitBreakerConfig             // circuit breaker of every endpoint, default disabled
+	CircuitBreakers map[Endpoint]CircuitBreakerConfig // per-endpoint circuit breakers, override CircuitBreaker
+
+	Interceptors     []Interceptor // wrap every attempt, in order
+	CallInterceptors []Interceptor // wrap every call including its retries, in order
+
+	Logger    *slog.Logger    // default no logging
+	Redaction RedactionPolicy // applied to everything logged by Logger
+
+	Coalesce bool // merge identical concurrent GET calls, see WithCoalescing
 }
 
+// RateLimit allows Limit requests every Per, with bursts of up to Burst requests (default 1).
+type RateLimit struct {
+	Limit int
+	Per   time.Duration
+	Burst int
+}
+
+// GetBackoff returns a Backoff of RetryInitial and RetryMax, nil unless RetryInitial is set.
+//
+// Deprecated: a Backoff holds the state of one request, use GetBackoffFactory.
 func (c *ClientOption) GetBackoff() *httputils.BackoffImpl {
 	if c.RetryInitial == 0 {
 		return nil
@@ -31,11 +67,117 @@ func (c *ClientOption) GetBackoff() *httputils.BackoffImpl {
 	}
 }
 
-func (c *ClientOption) GetBasePath() string {
+// GetBackoffFactory returns the factory of the Backoff of each request, see Service.NewBackoff.
+func (c *ClientOption) GetBackoffFactory() BackoffFactory {
+	if c.Backoff != nil {
+		return c.Backoff
+	}
+
+	initial, max := c.RetryInitial, c.RetryMax
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	if max < initial {
+		max = initial
+	}
+
+	switch c.RetryStrategy {
+	case BackoffEqualJitter:
+		return func() Backoff { return &httputils.EqualJitter{Initial: initial, Max: max} }
+	case BackoffDecorrelatedJitter:
+		return func() Backoff { return &httputils.DecorrelatedJitter{Initial: initial, Max: max} }
+	case BackoffConstant:
+		return func() Backoff { return &httputils.Constant{Interval: initial} }
+	default:
+		return func() Backoff { return &httputils.BackoffImpl{Initial: initial, Max: max} }
+	}
+}
+
+func (c *ClientOption) GetEnvironment() datatypes.Environment {
+	if c.Environment != "" {
+		return c.Environment
+	}
 	if c.IsSandbox {
-		return datatypes.SandboxBasePath
+		return datatypes.Sandbox
+	}
+	return datatypes.Production
+}
+
+func (c *ClientOption) GetResolver() (EnvironmentResolver, error) {
+	if c.BaseURL != "" {
+		u, err := NormalizeBaseURL(c.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return singleResolver(u), nil
+	}
+	if c.Resolver != nil {
+		return c.Resolver, nil
+	}
+	return DefaultResolver, nil
+}
+
+// GetBasePath returns the base URL of the environment, empty when it can't be resolved.
+func (c *ClientOption) GetBasePath() string {
+	resolver, err := c.GetResolver()
+	if err != nil {
+		return ""
+	}
+	u, _ := resolver.BaseURL(c.GetEnvironment())
+	return u
+}
+
+func (c *ClientOption) GetRateLimiters() map[Endpoint]*httputils.TokenBucket {
+	if len(c.RateLimits) == 0 {
+		return nil
+	}
+
+	limiters := make(map[Endpoint]*httputils.TokenBucket, len(c.RateLimits))
+	for endpoint, rl := range c.RateLimits {
+		if rl.Limit <= 0 || rl.Per <= 0 {
+			continue
+		}
+		limiters[endpoint] = httputils.NewTokenBucket(rl.Limit, rl.Per, rl.Burst)
+	}
+	return limiters
+}
+
+func (c *ClientOption) getCircuitBreakers() *circuitBreakers {
+	if c.CircuitBreaker == nil && len(c.CircuitBreakers) == 0 {
+		return nil
+	}
+	return &circuitBreakers{def: c.CircuitBreaker, perEndpoint: c.CircuitBreakers}
+}
+
+// GetLogger returns Logger with its handler wrapped by NewRedactingHandler.
+func (c *ClientOption) GetLogger() *slog.Logger {
+	if c.Logger == nil {
+		return nil
+	}
+	return slog.New(NewRedactingHandler(c.Logger.Handler(), c.Redaction))
+}
+
+func (c *ClientOption) GetHTTPClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.Proxy != nil {
+		return &http.Client{Transport: httputils.NewTransport(http.ProxyURL(c.Proxy))}
+	}
+	return httputils.DefaultClient()
+}
+
+func (c *ClientOption) GetMaxResponseBytes() int64 {
+	if c.MaxResponseBytes == 0 {
+		return DefaultMaxResponseBytes
+	}
+	if c.MaxResponseBytes < 0 {
+		return 0
 	}
-	return datatypes.BasePath
+	return c.MaxResponseBytes
 }
 
 func (c *ClientOption) GetUserAgent() string {
@@ -46,15 +188,34 @@ func (c *ClientOption) GetUserAgent() string {
 	return c.UserAgent
 }
 
+// WithRetry enables retries, pausing initial (default 100ms) at first and growing up to max (default 30s).
 func WithRetry(initial, max time.Duration) Option {
 	return func(c *ClientOption) {
 		c.NeedRetry = true
-		if initial == 0 {
-			c.RetryInitial = 100 * time.Millisecond
-		}
-		if max == 0 {
-			c.RetryMax = 10 * time.Second
-		}
+		c.RetryInitial = initial
+		c.RetryMax = max
+	}
+}
+
+// WithBackoffStrategy selects how retry pauses grow, see BackoffStrategy.
+func WithBackoffStrategy(strategy BackoffStrategy) Option {
+	return func(c *ClientOption) {
+		c.RetryStrategy = strategy
+	}
+}
+
+// WithBackoff uses a custom backoff, f is called once per request.
+func WithBackoff(f BackoffFactory) Option {
+	return func(c *ClientOption) {
+		c.Backoff = f
+	}
+}
+
+// WithRetryPolicy enables retries and decides which failures are retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *ClientOption) {
+		c.NeedRetry = true
+		c.RetryPolicy = p
 	}
 }
 
@@ -64,14 +225,136 @@ func WithSandbox() Option {
 	}
 }
 
+// WithEnvironment selects the environment calls are sent to, CallEnvironment overrides it per call.
+func WithEnvironment(env datatypes.Environment) Option {
+	return func(c *ClientOption) {
+		c.Environment = env
+	}
+}
+
+// WithBaseURL sends calls of every environment to baseURL, e.g. "http://localhost:8080/inApps/v1".
+// A malformed baseURL makes every call fail with ErrInvalidBaseURL.
+func WithBaseURL(baseURL string) Option {
+	return func(c *ClientOption) {
+		c.BaseURL = baseURL
+	}
+}
+
+// WithEnvironmentResolver maps environments to base URLs, see StaticResolver.
+func WithEnvironmentResolver(r EnvironmentResolver) Option {
+	return func(c *ClientOption) {
+		c.Resolver = r
+	}
+}
+
 func WithUserAgent(data string) Option {
 	return func(c *ClientOption) {
 		c.UserAgent = data
 	}
 }
 
+// WithProxy sends requests of the default client through proxy, ignored with WithHTTPClient.
+func WithProxy(proxy *url.URL) Option {
+	return func(c *ClientOption) {
+		c.Proxy = proxy
+	}
+}
+
+// WithMaxResponseBytes caps response bodies at n bytes, reading more fails with ErrResponseTooLarge.
+// n < 0 removes the cap.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *ClientOption) {
+		c.MaxResponseBytes = n
+	}
+}
+
+// WithTimeout bounds every call including its retries by d, unless CallTimeout sets another bound for the call.
+func WithTimeout(d time.Duration) Option {
+	return func(c *ClientOption) {
+		c.Timeout = d
+	}
+}
+
+// WithAttemptTimeout bounds every attempt by d, unless CallAttemptTimeout sets another bound for the call.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(c *ClientOption) {
+		c.AttemptTimeout = d
+	}
+}
+
 func WithHTTPClient(client *http.Client) Option {
 	return func(c *ClientOption) {
 		c.HTTPClient = client
 	}
 }
+
+// WithRateLimit limits requests to endpoint on the client side, e.g. WithRateLimit(EndpointTransactionHistory, 1000, time.Hour, 10)
+func WithRateLimit(endpoint Endpoint, limit int, per time.Duration, burst int) Option {
+	return func(c *ClientOption) {
+		if c.RateLimits == nil {
+			c.RateLimits = make(map[Endpoint]RateLimit)
+		}
+		c.RateLimits[endpoint] = RateLimit{Limit: limit, Per: per, Burst: burst}
+	}
+}
+
+// WithRateLimitFailFast makes rate limited calls return ErrRateLimited immediately instead of waiting.
+func WithRateLimitFailFast() Option {
+	return func(c *ClientOption) {
+		c.RateLimitFailFast = true
+	}
+}
+
+// WithCircuitBreaker guards every endpoint with its own circuit breaker configured by cfg.
+func WithCircuitBreaker(cfg CircuitBreakerConfig) Option {
+	return func(c *ClientOption) {
+		c.CircuitBreaker = &cfg
+	}
+}
+
+// WithEndpointCircuitBreaker configures the circuit breaker of endpoint, overriding WithCircuitBreaker.
+func WithEndpointCircuitBreaker(endpoint Endpoint, cfg CircuitBreakerConfig) Option {
+	return func(c *ClientOption) {
+		if c.CircuitBreakers == nil {
+			c.CircuitBreakers = make(map[Endpoint]CircuitBreakerConfig)
+		}
+		c.CircuitBreakers[endpoint] = cfg
+	}
+}
+
+// WithInterceptors appends interceptors to the chain wrapping every attempt of Service.Do.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *ClientOption) {
+		c.Interceptors = append(c.Interceptors, interceptors...)
+	}
+}
+
+// WithCallInterceptors appends interceptors to the chain wrapping every call of Service.Do with all its attempts.
+func WithCallInterceptors(interceptors ...Interceptor) Option {
+	return func(c *ClientOption) {
+		c.CallInterceptors = append(c.CallInterceptors, interceptors...)
+	}
+}
+
+// WithLogger logs calls, retries and Apple error codes to l, identifiers are redacted, see WithRedactionPolicy.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *ClientOption) {
+		c.Logger = l
+	}
+}
+
+// WithRedactionPolicy sets how identifiers are logged by WithLogger, default all hashed.
+func WithRedactionPolicy(p RedactionPolicy) Option {
+	return func(c *ClientOption) {
+		c.Redaction = p
+	}
+}
+
+// WithCoalescing merges identical GET calls in flight at the same time into one request whose result is shared.
+// Calls are identical when their endpoint, parameters, environment and bearer match, calls made with
+// CallOptions or a ResponseMeta are never merged.
+func WithCoalescing() Option {
+	return func(c *ClientOption) {
+		c.Coalesce = true
+	}
+}
@@ -0,0 +1,168 @@
+// Package otelappstore instruments appstoreapi.Service with OpenTelemetry, see https://opentelemetry.io/docs/languages/go/
+//
+//	s := appstoreapi.NewAppStoreService(ctx, otelappstore.Instrument())
+//
+// It is a module of its own, so that only the applications importing it depend on OpenTelemetry.
+package otelappstore
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	appleapigoclient "github.com/gh73962/appleapis"
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ScopeName = "github.com/gh73962/appleapis/appstore/api/v1/otelappstore"
+
+// Attribute keys set on spans and metrics.
+const (
+	OperationKey   = attribute.Key("appstore.operation")
+	EndpointKey    = attribute.Key("appstore.endpoint")
+	EnvironmentKey = attribute.Key("appstore.environment")
+	ErrorCodeKey   = attribute.Key("appstore.error_code")
+	AttemptKey     = attribute.Key("appstore.attempt")
+)
+
+type config struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+type Option func(*config)
+
+// WithTracerProvider default otel.GetTracerProvider()
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tp = tp
+	}
+}
+
+// WithMeterProvider default otel.GetMeterProvider()
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.mp = mp
+	}
+}
+
+// Instrument returns an appstoreapi.Option producing one span per call with a child span per attempt,
+// an "appstore.call.duration" histogram in seconds and an "appstore.call.retries" histogram.
+func Instrument(options ...Option) appstoreapi.Option {
+	c := config{
+		tp: otel.GetTracerProvider(),
+		mp: otel.GetMeterProvider(),
+	}
+	for _, opt := range options {
+		opt(&c)
+	}
+
+	tracer := c.tp.Tracer(ScopeName, trace.WithInstrumentationVersion(appleapigoclient.Version))
+	meter := c.mp.Meter(ScopeName, metric.WithInstrumentationVersion(appleapigoclient.Version))
+
+	duration, err := meter.Float64Histogram("appstore.call.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of App Store Server API calls, including retries."))
+	if err != nil {
+		otel.Handle(err)
+	}
+	retries, err := meter.Int64Histogram("appstore.call.retries",
+		metric.WithUnit("{retry}"),
+		metric.WithDescription("Number of retries of App Store Server API calls."))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	i := instrumentation{tracer: tracer, duration: duration, retries: retries}
+	return func(opt *appstoreapi.ClientOption) {
+		appstoreapi.WithCallInterceptors(i.call)(opt)
+		appstoreapi.WithInterceptors(i.attempt)(opt)
+	}
+}
+
+type instrumentation struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	retries  metric.Int64Histogram
+}
+
+func (i *instrumentation) call(info *appstoreapi.CallInfo, req *http.Request, next appstoreapi.Invoker) (*http.Response, error) {
+	ctx, span := i.tracer.Start(req.Context(), spanName(info),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(callAttributes(info)...))
+	defer span.End()
+
+	start := time.Now()
+	resp, err := next(req.WithContext(ctx))
+	elapsed := time.Since(start)
+
+	result := resultAttributes(resp, err)
+	span.SetAttributes(result...)
+	span.SetAttributes(AttemptKey.Int(info.Attempt))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if i.duration != nil {
+		i.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(append(callAttributes(info), result...)...))
+	}
+	if i.retries != nil && info.Attempt > 0 {
+		i.retries.Record(ctx, int64(info.Attempt-1), metric.WithAttributes(callAttributes(info)...))
+	}
+	return resp, err
+}
+
+func (i *instrumentation) attempt(info *appstoreapi.CallInfo, req *http.Request, next appstoreapi.Invoker) (*http.Response, error) {
+	ctx, span := i.tracer.Start(req.Context(), spanName(info)+" attempt",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(callAttributes(info)...),
+		trace.WithAttributes(
+			AttemptKey.Int(info.Attempt),
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+		))
+	defer span.End()
+
+	resp, err := next(req.WithContext(ctx))
+	span.SetAttributes(resultAttributes(resp, err)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+func spanName(info *appstoreapi.CallInfo) string {
+	if info.Operation == "" {
+		return "appstoreapi.Do"
+	}
+	return "appstoreapi." + info.Operation
+}
+
+func callAttributes(info *appstoreapi.CallInfo) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		OperationKey.String(info.Operation),
+		EndpointKey.String(string(info.Endpoint)),
+		EnvironmentKey.String(string(info.Environment)),
+	}
+}
+
+func resultAttributes(resp *http.Response, err error) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if resp != nil {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	var errResp *datatypes.ErrorResponse
+	if errors.As(err, &errResp) && errResp.ErrorCode != 0 {
+		attrs = append(attrs, ErrorCodeKey.Int64(errResp.ErrorCode))
+	}
+	return attrs
+}
@@ -0,0 +1,87 @@
+package otelappstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrument(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"errorCode":5000001,"errorMessage":"An unknown error occurred."}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	s := appstoreapi.NewAppStoreService(context.Background(),
+		appstoreapi.WithHTTPClient(srv.Client()),
+		appstoreapi.WithRetry(time.Millisecond, time.Millisecond),
+		Instrument(WithTracerProvider(tp), WithMeterProvider(mp)),
+	)
+	s.BasePath = srv.URL + "/"
+
+	if _, err := s.AllSubscriptionStatuses(context.Background(), "bearer", "1", 0); err != nil {
+		t.Fatalf("AllSubscriptionStatuses() error = %v", err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("spans = %d, want 3", len(ended))
+	}
+	call := ended[2]
+	if call.Name() != "appstoreapi.AllSubscriptionStatuses" {
+		t.Errorf("call span name = %q", call.Name())
+	}
+	for _, attempt := range ended[:2] {
+		if attempt.Parent().SpanID() != call.SpanContext().SpanID() {
+			t.Errorf("attempt span %q is not a child of the call span", attempt.Name())
+		}
+	}
+	var errorCode int64
+	for _, kv := range ended[0].Attributes() {
+		if kv.Key == ErrorCodeKey {
+			errorCode = kv.Value.AsInt64()
+		}
+	}
+	if errorCode != 5000001 {
+		t.Errorf("first attempt %s = %d, want 5000001", ErrorCodeKey, errorCode)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	var retries int64 = -1
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "appstore.call.retries" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Histogram[int64]).DataPoints {
+				retries = dp.Sum
+			}
+		}
+	}
+	if retries != 1 {
+		t.Errorf("appstore.call.retries sum = %d, want 1", retries)
+	}
+}
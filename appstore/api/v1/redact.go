@@ -0,0 +1,123 @@
+package appstoreapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// RedactMode says how an identifier is written to logs.
+type RedactMode int
+
+const (
+	RedactHash RedactMode = iota // first 16 hex digits of its HMAC-SHA256, so log lines can still be correlated
+	RedactMask                   // replaced by "[REDACTED]"
+	RedactNone                   // logged as is
+)
+
+// RedactionPolicy decides how identifiers are logged, the zero value hashes all of them with a key of the process.
+type RedactionPolicy struct {
+	TransactionID   RedactMode // transactionId, originalTransactionId, webOrderLineItemId and orderId
+	AppAccountToken RedactMode // appAccountToken
+	Bearer          RedactMode // authorization and bearer
+	HashKey         []byte     // HMAC key shared by processes logging correlated hashes, default random per process
+}
+
+var redactedKeys = map[string]func(p *RedactionPolicy) RedactMode{
+	"transactionid":         func(p *RedactionPolicy) RedactMode { return p.TransactionID },
+	"originaltransactionid": func(p *RedactionPolicy) RedactMode { return p.TransactionID },
+	"weborderlineitemid":    func(p *RedactionPolicy) RedactMode { return p.TransactionID },
+	"orderid":               func(p *RedactionPolicy) RedactMode { return p.TransactionID },
+	"appaccounttoken":       func(p *RedactionPolicy) RedactMode { return p.AppAccountToken },
+	"authorization":         func(p *RedactionPolicy) RedactMode { return p.Bearer },
+	"bearer":                func(p *RedactionPolicy) RedactMode { return p.Bearer },
+}
+
+// Redact returns value as it should be logged under key, keys are matched case-insensitively.
+func (p *RedactionPolicy) Redact(key, value string) string {
+	mode, ok := redactedKeys[strings.ToLower(key)]
+	if !ok || value == "" {
+		return value
+	}
+
+	switch mode(p) {
+	case RedactNone:
+		return value
+	case RedactMask:
+		return "[REDACTED]"
+	}
+	hashKey := p.HashKey
+	if len(hashKey) == 0 {
+		hashKey = processHashKey()
+	}
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// processHashKey returns the random HMAC key of policies without HashKey. A plain hash of low-entropy
+// identifiers such as transaction IDs could be reversed by trying them all.
+var processHashKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("appstoreapi: reading a random hash key: " + err.Error())
+	}
+	return key
+})
+
+func (p *RedactionPolicy) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = p.redactAttr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	if _, ok := redactedKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, p.Redact(a.Key, a.Value.String()))
+	}
+	return a
+}
+
+type redactingHandler struct {
+	handler slog.Handler
+	policy  *RedactionPolicy
+}
+
+// NewRedactingHandler redacts identifiers in every record passed to h according to p, including those in
+// groups and slog.LogValuer values such as datatypes.JWSTransactionDecodedPayload.
+func NewRedactingHandler(h slog.Handler, p RedactionPolicy) slog.Handler {
+	return &redactingHandler{handler: h, policy: &p}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.policy.redactAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.policy.redactAttr(a)
+	}
+	return &redactingHandler{handler: h.handler.WithAttrs(redacted), policy: h.policy}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{handler: h.handler.WithGroup(name), policy: h.policy}
+}
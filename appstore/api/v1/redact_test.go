@@ -0,0 +1,98 @@
+package appstoreapi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestRedactionPolicyRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RedactionPolicy
+		key    string
+		value  string
+		want   string
+	}{
+		{"hash", RedactionPolicy{HashKey: []byte("key")}, "transactionId", "123", "hmac:a7f7739b1dc5b4e9"},
+		{"mask", RedactionPolicy{AppAccountToken: RedactMask}, "appAccountToken", "token", "[REDACTED]"},
+		{"none", RedactionPolicy{Bearer: RedactNone}, "Authorization", "Bearer x", "Bearer x"},
+		{"other key", RedactionPolicy{}, "productId", "com.xxx", "com.xxx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Redact(tt.key, tt.value); got != tt.want {
+				t.Errorf("Redact() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactionPolicyProcessKey(t *testing.T) {
+	var p RedactionPolicy
+	got := p.Redact("transactionId", "123")
+	if got == "sha256:a665a45920422f9d" || !strings.HasPrefix(got, "hmac:") {
+		t.Errorf("Redact() = %v, want an HMAC with a random key", got)
+	}
+	if again := p.Redact("originalTransactionId", "123"); again != got {
+		t.Errorf("Redact() = %v then %v, want the same hash within the process", got, again)
+	}
+}
+
+func TestRedactingHandlerPayload(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil), RedactionPolicy{TransactionID: RedactMask}))
+
+	l.Info("decoded", "transaction", &datatypes.JWSTransactionDecodedPayload{
+		TransactionID:         "2000000000000001",
+		OriginalTransactionID: "2000000000000000",
+		AppAccountToken:       "7e3fb20b-4cdb-47cc-936d-99d65f608138",
+		ProductID:             "com.xxx.sub",
+	})
+
+	out := buf.String()
+	for _, raw := range []string{"2000000000000001", "2000000000000000", "7e3fb20b-4cdb-47cc-936d-99d65f608138"} {
+		if strings.Contains(out, raw) {
+			t.Errorf("log %s contains %s", out, raw)
+		}
+	}
+	if !strings.Contains(out, "com.xxx.sub") {
+		t.Errorf("log %s lost productId", out)
+	}
+}
+
+func TestServiceLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		WithRedactionPolicy(RedactionPolicy{TransactionID: RedactMask}),
+	)
+	s.BasePath = srv.URL + "/"
+
+	if _, err := s.TransactionInfo(context.Background(), "secret-bearer", "2000000000000001"); err == nil {
+		t.Fatal("TransactionInfo() error = nil, want 404")
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "2000000000000001") || strings.Contains(out, "secret-bearer") {
+		t.Errorf("log contains identifiers: %s", out)
+	}
+	for _, want := range []string{`"msg":"appstoreapi: call failed"`, `"errorCode":4040010`, `"operation":"TransactionInfo"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log %s does not contain %s", out, want)
+		}
+	}
+}
@@ -2,29 +2,34 @@ package appstoreapi
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"net/url"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
 )
 
 // RefundHistory see https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
-func (s *Service) RefundHistory(ctx context.Context, bearer, transactionID, revision string) (*datatypes.OrderLookupResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, s.BasePath+"lookup/"+transactionID+"?revision="+revision, nil)
-	if err != nil {
+// Pages are read by passing the Revision of the previous response while it HasMore, starting with "".
+func (s *Service) RefundHistory(ctx context.Context, bearer, transactionID, revision string, opts ...CallOption) (*datatypes.RefundHistoryResponse, error) {
+	if err := validateTransactionID(transactionID); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
-	resp, err := s.Do(ctx, req)
-	if err != nil {
-		return nil, err
+	var query url.Values
+	if revision != "" {
+		query = url.Values{"revision": {revision}}
 	}
-	defer resp.Body.Close()
 
-	var rsp datatypes.OrderLookupResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	var rsp datatypes.RefundHistoryResponse
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointRefundHistory,
+		method:   http.MethodGet,
+		path:     []string{"refund", "lookup", transactionID},
+		query:    query,
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
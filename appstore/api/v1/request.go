@@ -0,0 +1,130 @@
+package appstoreapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// ErrInvalidIdentifier is returned before sending a request whose identifier is malformed.
+var ErrInvalidIdentifier = errors.New("invalid identifier")
+
+var (
+	transactionIDPattern = regexp.MustCompile(`^[0-9]{1,32}$`)
+	orderIDPattern       = regexp.MustCompile(`^[A-Za-z0-9]{1,64}$`)
+	tokenPattern         = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+)
+
+func validateTransactionID(id string) error {
+	return validate("transactionId", id, transactionIDPattern)
+}
+
+func validateOrderID(id string) error {
+	return validate("orderId", id, orderIDPattern)
+}
+
+func validateToken(name, token string) error {
+	return validate(name, token, tokenPattern)
+}
+
+// validateConsumption runs cr.Validate, a nil cr is invalid.
+func validateConsumption(cr *datatypes.ConsumptionRequest) error {
+	if cr == nil {
+		return fmt.Errorf("%w: missing", datatypes.ErrInvalidConsumptionRequest)
+	}
+	return cr.Validate()
+}
+
+func validate(name, value string, pattern *regexp.Regexp) error {
+	if !pattern.MatchString(value) {
+		return fmt.Errorf("%w: %s %q", ErrInvalidIdentifier, name, value)
+	}
+	return nil
+}
+
+// apiRequest describes a request to an endpoint, every Service method builds and sends it with call.
+type apiRequest struct {
+	endpoint Endpoint
+	method   string
+	path     []string // segments relative to the base path, escaped by call
+	query    url.Values
+	body     any // encoded as JSON when not nil
+}
+
+// url returns the escaped URL of r under basePath.
+func (r *apiRequest) url(basePath string) string {
+	segments := make([]string, len(r.path))
+	for i, segment := range r.path {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := basePath + strings.Join(segments, "/")
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	return u
+}
+
+// call sends r authorized by bearer and decodes the response into out, when not nil.
+func (s *Service) call(ctx context.Context, bearer string, r apiRequest, out any) error {
+	var body io.Reader
+	if r.body != nil {
+		var buff bytes.Buffer
+		if err := json.NewEncoder(&buff).Encode(r.body); err != nil {
+			return err
+		}
+		body = &buff
+	}
+
+	basePath, err := s.basePath(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(r.method, r.url(basePath), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Set("User-Agent", s.UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	var data []byte
+	if s.flights != nil && r.method == http.MethodGet && coalescable(ctx) {
+		data, err = s.flights.do(ctx, flightKey(r.endpoint, s.environment(ctx), req), func() ([]byte, error) {
+			return s.fetch(ctx, r, req)
+		})
+	} else {
+		data, err = s.fetch(ctx, r, req)
+	}
+	if err != nil || out == nil || data == nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// fetch sends req built from r and returns the body of the response, nil when its status has none.
+// The body was read, and checked to be complete JSON, by the attempt that received it.
+func (s *Service) fetch(ctx context.Context, r apiRequest, req *http.Request) ([]byte, error) {
+	resp, err := s.Do(withEndpoint(ctx, r.endpoint), req)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.endpoint.response().hasBody(resp.StatusCode) {
+		return nil, nil
+	}
+	return io.ReadAll(resp.Body)
+}
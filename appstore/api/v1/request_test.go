@@ -0,0 +1,156 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestAPIRequestURL(t *testing.T) {
+	tests := []struct {
+		name string
+		r    apiRequest
+		want string
+	}{
+		{
+			name: "path",
+			r:    apiRequest{path: []string{"history", "2000000000000001"}},
+			want: "https://example.com/inApps/v1/history/2000000000000001",
+		},
+		{
+			name: "escaped segment",
+			r:    apiRequest{path: []string{"lookup", "a/../b?c"}},
+			want: "https://example.com/inApps/v1/lookup/a%2F..%2Fb%3Fc",
+		},
+		{
+			name: "query",
+			r:    apiRequest{path: []string{"notifications", "history"}, query: url.Values{"paginationToken": {"a&b=c"}}},
+			want: "https://example.com/inApps/v1/notifications/history?paginationToken=a%26b%3Dc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.url("https://example.com/inApps/v1/"); got != tt.want {
+				t.Errorf("url() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceValidatesIdentifiers(t *testing.T) {
+	s := NewAppStoreService(context.Background())
+	s.BasePath = "http://127.0.0.1:0/"
+
+	if _, err := s.TransactionInfo(context.Background(), "bearer", "123/../../lookup"); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("TransactionInfo() error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+	if _, err := s.LookUpOrderID(context.Background(), "bearer", "MK5TTTVWJH?x=1"); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("LookUpOrderID() error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
+
+func TestServiceRefundHistoryPath(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+	s.BasePath = srv.URL + "/inApps/v1/"
+
+	for revision, want := range map[string]string{
+		"":                "/inApps/v1/refund/lookup/2000000000000001",
+		"revision_output": "/inApps/v1/refund/lookup/2000000000000001?revision=revision_output",
+	} {
+		if _, err := s.RefundHistory(context.Background(), "bearer", "2000000000000001", revision); err != nil {
+			t.Fatalf("RefundHistory(%q) error = %v", revision, err)
+		}
+		if got != want {
+			t.Errorf("RefundHistory(%q) requested %s, want %s", revision, got, want)
+		}
+	}
+}
+
+func TestServiceSendConsumptionInformation(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		cr       *datatypes.ConsumptionRequest
+		wantBody string
+		wantErrs []string // fields reported by the validation
+	}{
+		{
+			name: "zero values are sent",
+			cr:   &datatypes.ConsumptionRequest{CustomerConsented: true, PlayTime: datatypes.PlayTime1To6Hours},
+			wantBody: `{"accountTenure":0,"appAccountToken":"","consumptionStatus":0,"customerConsented":true,` +
+				`"deliveryStatus":0,"lifetimeDollarsPurchased":0,"lifetimeDollarsRefunded":0,"platform":0,"playTime":3,` +
+				`"sampleContentProvided":false,"userStatus":0}`,
+		},
+		{
+			name: "refund preference",
+			cr: &datatypes.ConsumptionRequest{CustomerConsented: true, RefundPreference: datatypes.RefundPreferenceDecline,
+				AppAccountToken: "7e3fb20b-4cdb-47cc-936d-99d65f608138"},
+			wantBody: `{"accountTenure":0,"appAccountToken":"7e3fb20b-4cdb-47cc-936d-99d65f608138","consumptionStatus":0,` +
+				`"customerConsented":true,"deliveryStatus":0,"lifetimeDollarsPurchased":0,"lifetimeDollarsRefunded":0,` +
+				`"platform":0,"playTime":0,"refundPreference":2,"sampleContentProvided":false,"userStatus":0}`,
+		},
+		{
+			name: "invalid",
+			cr: &datatypes.ConsumptionRequest{AppAccountToken: "account-1", DeliveryStatus: 6,
+				LifetimeDollarsRefunded: -1, UserStatus: datatypes.UserStatusLimitedAccess},
+			wantErrs: []string{"customerConsented", "appAccountToken", "deliveryStatus", "lifetimeDollarsRefunded"},
+		},
+		{
+			name:     "missing",
+			wantErrs: []string{"missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = ""
+			s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+			s.BasePath = srv.URL + "/"
+
+			err := s.SendConsumptionInformation(context.Background(), "bearer", "1", tt.cr)
+			if tt.wantErrs == nil {
+				if err != nil {
+					t.Fatalf("SendConsumptionInformation() error = %v", err)
+				}
+				if strings.TrimSpace(body) != tt.wantBody {
+					t.Errorf("body = %s\nwant %s", body, tt.wantBody)
+				}
+				return
+			}
+			if !errors.Is(err, datatypes.ErrInvalidConsumptionRequest) {
+				t.Fatalf("SendConsumptionInformation() error = %v, want %v", err, datatypes.ErrInvalidConsumptionRequest)
+			}
+			for _, field := range tt.wantErrs {
+				if !strings.Contains(err.Error(), field) {
+					t.Errorf("SendConsumptionInformation() error = %v, want %s reported", err, field)
+				}
+			}
+			if strings.Contains(err.Error(), "userStatus") {
+				t.Errorf("SendConsumptionInformation() error = %v, userStatus is valid", err)
+			}
+			if body != "" {
+				t.Error("invalid request sent")
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package appstoreapi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+var (
+	// ErrInvalidBaseURL is returned by every call of a Service configured with a malformed base URL.
+	ErrInvalidBaseURL = errors.New("invalid base URL")
+	// ErrUnknownEnvironment is returned for calls to an environment the resolver has no base URL for.
+	ErrUnknownEnvironment = errors.New("unknown environment")
+)
+
+// EnvironmentResolver maps an environment to the base URL its requests are sent to.
+type EnvironmentResolver interface {
+	BaseURL(env datatypes.Environment) (string, error)
+}
+
+// StaticResolver maps every environment to a fixed base URL, e.g. to point datatypes.LocalTesting
+// at a local stand-in. Base URLs are validated and normalized to end with a slash.
+type StaticResolver map[datatypes.Environment]string
+
+func (r StaticResolver) BaseURL(env datatypes.Environment) (string, error) {
+	u, ok := r[env]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownEnvironment, env)
+	}
+	return NormalizeBaseURL(u)
+}
+
+// DefaultResolver resolves the environments hosted by Apple.
+var DefaultResolver = StaticResolver{
+	datatypes.Production: datatypes.BasePath,
+	datatypes.Sandbox:    datatypes.SandboxBasePath,
+}
+
+// singleResolver sends every environment to the same base URL.
+type singleResolver string
+
+func (r singleResolver) BaseURL(datatypes.Environment) (string, error) {
+	return string(r), nil
+}
+
+// NormalizeBaseURL checks that raw is an absolute http(s) URL without query or fragment and adds the trailing slash
+// the endpoint paths are appended to, e.g. "http://localhost:8080/inApps/v1" becomes "http://localhost:8080/inApps/v1/".
+func NormalizeBaseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidBaseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%w: %q must use http or https", ErrInvalidBaseURL, raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%w: %q has no host", ErrInvalidBaseURL, raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("%w: %q must not have user info, query or fragment", ErrInvalidBaseURL, raw)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String(), nil
+}
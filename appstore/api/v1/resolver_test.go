@@ -0,0 +1,64 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "http://localhost:8080/inApps/v1", want: "http://localhost:8080/inApps/v1/"},
+		{raw: "https://proxy.internal/inApps/v1/", want: "https://proxy.internal/inApps/v1/"},
+		{raw: "localhost:8080", wantErr: true},
+		{raw: "ftp://localhost/", wantErr: true},
+		{raw: "https://proxy.internal/?a=b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := NormalizeBaseURL(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeBaseURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeBaseURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceEnvironmentResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/local/inApps/v1/lookup/MK5TTTVWJH" {
+			t.Errorf("path = %v", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithEnvironmentResolver(StaticResolver{datatypes.LocalTesting: srv.URL + "/local/inApps/v1"}),
+		WithEnvironment(datatypes.LocalTesting),
+	)
+	if _, err := s.LookUpOrderID(context.Background(), "bearer", "MK5TTTVWJH"); err != nil {
+		t.Errorf("LookUpOrderID() error = %v", err)
+	}
+	_, err := s.LookUpOrderID(context.Background(), "bearer", "MK5TTTVWJH", CallEnvironment(datatypes.Sandbox))
+	if !errors.Is(err, ErrUnknownEnvironment) {
+		t.Errorf("LookUpOrderID() sandbox error = %v, want %v", err, ErrUnknownEnvironment)
+	}
+
+	s = NewAppStoreService(context.Background(), WithBaseURL("localhost:8080"))
+	if _, err := s.LookUpOrderID(context.Background(), "bearer", "MK5TTTVWJH"); !errors.Is(err, ErrInvalidBaseURL) {
+		t.Errorf("LookUpOrderID() error = %v, want %v", err, ErrInvalidBaseURL)
+	}
+}
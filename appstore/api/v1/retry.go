@@ -0,0 +1,112 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+// RetryPolicy decides whether a failed attempt is retried.
+type RetryPolicy interface {
+	// Retry reports whether to try again after attempt (starting at 1) failed with resp and err,
+	// elapsed is the time since the first attempt started, plus the wait resp asks for with Retry-After.
+	// The body of resp has already been consumed.
+	Retry(attempt int, elapsed time.Duration, resp *http.Response, err error) bool
+}
+
+// NoRetry never retries, use it to opt a call out of retries with CallRetryPolicy or ContextWithRetryPolicy.
+var NoRetry RetryPolicy = noRetry{}
+
+type noRetry struct{}
+
+func (noRetry) Retry(int, time.Duration, *http.Response, error) bool { return false }
+
+// DefaultRetryPolicy retries what IsRetryable classifies as transient, within MaxAttempts and MaxElapsedTime.
+type DefaultRetryPolicy struct {
+	MaxAttempts    int                                       // 0 means unlimited, bounded only by ctx
+	MaxElapsedTime time.Duration                             // 0 means unlimited, bounded only by ctx
+	Classify       func(resp *http.Response, err error) bool // default IsRetryable
+}
+
+func (p *DefaultRetryPolicy) Retry(attempt int, elapsed time.Duration, resp *http.Response, err error) bool {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return false
+	}
+	if p.MaxElapsedTime > 0 && elapsed >= p.MaxElapsedTime {
+		return false
+	}
+	if p.Classify != nil {
+		return p.Classify(resp, err)
+	}
+	return IsRetryable(resp, err)
+}
+
+// IsRetryable reports whether a failure is transient: 5xx, 408, 429, an unexpected EOF, a connection reset,
+// a timeout, or one of the retryable App Store error codes.
+func IsRetryable(resp *http.Response, err error) bool {
+	if err == nil && resp != nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || isTimeout(err) {
+		return true
+	}
+
+	var errResp *datatypes.ErrorResponse
+	errors.As(err, &errResp)
+
+	// an ErrorResponse without a response, e.g. from fake.Client, is classified by its status
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	} else if errResp != nil {
+		status = errResp.HTTPStatus
+	}
+
+	if http.StatusInternalServerError <= status && status <= 599 {
+		return true
+	}
+
+	if status == http.StatusTooManyRequests || status == http.StatusRequestTimeout {
+		return true
+	}
+
+	if errResp == nil {
+		return false
+	}
+	// see https://developer.apple.com/documentation/appstoreserverapi/error_codes
+	switch errResp.ErrorCode {
+	case 4040002, 4040004, 5000001, 4040006:
+		return true
+	}
+	return false
+}
+
+// isTimeout reports whether err is a deadline or network timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy overrides the retry policy of Service for calls made with ctx,
+// e.g. ContextWithRetryPolicy(ctx, NoRetry) for a call that must not be repeated. CallRetryPolicy
+// sets it for a single call, taking precedence over the policy of ctx.
+func ContextWithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+func retryPolicyFromContext(ctx context.Context) (RetryPolicy, bool) {
+	p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	return p, ok
+}
@@ -0,0 +1,59 @@
+package appstoreapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{name: "ok", resp: &http.Response{StatusCode: http.StatusOK}},
+		{name: "accepted", resp: &http.Response{StatusCode: http.StatusAccepted}},
+		{name: "no content", resp: &http.Response{StatusCode: http.StatusNoContent}},
+		{
+			name: "unexpected EOF of a 200",
+			resp: &http.Response{StatusCode: http.StatusOK},
+			err:  fmt.Errorf("read body: %w", io.ErrUnexpectedEOF),
+			want: true,
+		},
+		{
+			name: "bad gateway",
+			resp: &http.Response{StatusCode: http.StatusBadGateway},
+			err:  &datatypes.ErrorResponse{HTTPStatus: http.StatusBadGateway},
+			want: true,
+		},
+		{
+			name: "error response without a response",
+			err:  &datatypes.ErrorResponse{HTTPStatus: http.StatusTooManyRequests, ErrorCode: datatypes.ErrorCodeRateLimitExceeded},
+			want: true,
+		},
+		{
+			name: "final error response without a response",
+			err:  &datatypes.ErrorResponse{HTTPStatus: http.StatusNotFound, ErrorCode: datatypes.ErrorCodeTransactionIDNotFound},
+		},
+		{
+			name: "retryable error code",
+			resp: &http.Response{StatusCode: http.StatusNotFound},
+			err:  &datatypes.ErrorResponse{HTTPStatus: http.StatusNotFound, ErrorCode: 4040002},
+			want: true,
+		},
+		{name: "other error", err: errors.New("invalid request")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.resp, tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
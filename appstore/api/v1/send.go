@@ -1,9 +1,11 @@
 package appstoreapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"time"
@@ -13,59 +15,238 @@ import (
 )
 
 func (s *Service) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
-	if s.NeedRetry {
-		return sendAndRetry(ctx, s.client, req, s.BackOff)
+	endpoint, _ := EndpointFromContext(ctx)
+	info := &CallInfo{
+		Operation:   endpoint.Operation(),
+		Endpoint:    endpoint,
+		Environment: s.environment(ctx),
 	}
+	call := chain(s.callInterceptors, info, func(req *http.Request) (*http.Response, error) {
+		return s.send(req, info)
+	})
 
-	return send(ctx, s.client, req)
+	if header := callOptionsFromContext(ctx).header; len(header) > 0 {
+		req = req.Clone(ctx)
+		for key, values := range header {
+			for _, v := range values {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+
+	start := time.Now()
+	resp, err := call(req.WithContext(ctx))
+	if meta := responseMetaFromContext(ctx); meta != nil {
+		meta.fill(info, resp, time.Since(start))
+	}
+	return resp, err
 }
 
-func SendRequest(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
-	return send(ctx, client, req)
+// send runs the attempts of a call, info is kept at the current attempt.
+func (s *Service) send(req *http.Request, info *CallInfo) (*http.Response, error) {
+	ctx := req.Context()
+	opts := callOptionsFromContext(ctx)
+	do := func(req *http.Request, attempt int, pause time.Duration) (*http.Response, error) {
+		info.Attempt = attempt
+		info.Pause = pause
+		// the token is taken before the attempt timeout starts, which bounds only the exchange with Apple
+		if err := s.waitRateLimit(req.Context()); err != nil {
+			return nil, err
+		}
+		intercept := func(req *http.Request) (*http.Response, error) {
+			return s.intercept(req, *info)
+		}
+		if d := s.attemptTimeoutOf(opts); d > 0 {
+			return withAttemptTimeout(req, d, intercept)
+		}
+		return intercept(req)
+	}
+
+	policy, ok := retryPolicyFromContext(ctx)
+	if !ok && s.NeedRetry {
+		policy = s.RetryPolicy
+		if policy == nil {
+			policy = &DefaultRetryPolicy{}
+		}
+	}
+	if policy != nil {
+		var bo Backoff
+		switch {
+		case opts.backoff != nil:
+			bo = opts.backoff()
+		case s.BackOff != nil:
+			bo = s.BackOff
+		case s.NewBackoff != nil:
+			bo = s.NewBackoff()
+		}
+		return sendAndRetry(ctx, do, req, bo, policy)
+	}
+
+	return send(ctx, do, req)
+}
+
+func (s *Service) environment(ctx context.Context) datatypes.Environment {
+	if env := callOptionsFromContext(ctx).environment; env != "" {
+		return env
+	}
+	if s.env != "" {
+		return s.env
+	}
+	if s.BasePath == datatypes.SandboxBasePath {
+		return datatypes.Sandbox
+	}
+	return datatypes.Production
 }
 
-func send(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+// doFunc sends attempt (starting at 1) of a request after pausing for pause,
+// non 2xx responses are returned with a *datatypes.ErrorResponse.
+type doFunc func(req *http.Request, attempt int, pause time.Duration) (*http.Response, error)
+
+func clientDo(client *http.Client) doFunc {
+	return func(req *http.Request, _ int, _ time.Duration) (*http.Response, error) {
+		return doRequest(client, req, DefaultMaxResponseBytes)
+	}
+}
+
+// doRequest sends req with client, or the library's default client when nil,
+// reading more than maxBytes of the response body fails with ErrResponseTooLarge.
+func doRequest(client *http.Client, req *http.Request, maxBytes int64) (*http.Response, error) {
 	if client == nil {
-		client = http.DefaultClient
+		client = httputils.DefaultClient()
+	}
+	resp, err := client.Do(req)
+	if err == nil && maxBytes > 0 {
+		resp.Body = httputils.LimitBody(resp.Body, maxBytes)
+	}
+	if err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		err = decodeErrorResponse(resp)
+	}
+	return resp, err
+}
+
+// attemptTimeoutOf returns the attempt timeout of a call made with opts.
+func (s *Service) attemptTimeoutOf(opts *callOptions) time.Duration {
+	if opts.attemptTimeout > 0 {
+		return opts.attemptTimeout
+	}
+	return s.attemptTimeout
+}
+
+// attempt sends req once, guarded by the circuit breaker of its endpoint. Its rate limit token was taken by send.
+func (s *Service) attempt(req *http.Request) (*http.Response, error) {
+	endpoint, _ := EndpointFromContext(req.Context())
+	b := s.breakers.get(endpoint)
+	if b != nil {
+		if err := b.allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := doRequest(s.client, req, s.maxResponseBytes)
+	if err == nil {
+		err = readBody(resp, endpoint)
+	}
+	if b != nil {
+		b.done(circuitOutcome(resp, err))
+	}
+	return resp, err
+}
+
+// readBody reads the body of a successful response within its attempt, so that a body cut short fails the
+// attempt with io.ErrUnexpectedEOF and can be retried. The body is left readable again for the caller.
+func readBody(resp *http.Response, endpoint Endpoint) error {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return nil
 	}
-	resp, err := client.Do(req.WithContext(ctx))
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
 	if err != nil {
+		return err
+	}
+	if endpoint != "" && endpoint.response().hasBody(resp.StatusCode) && !json.Valid(data) {
+		return fmt.Errorf("%w: incomplete JSON response body", io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// waitRateLimit takes a token from the limiter of the endpoint in ctx, if one is configured.
+func (s *Service) waitRateLimit(ctx context.Context) error {
+	endpoint, ok := EndpointFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	lim := s.limiters[endpoint]
+	if lim == nil {
+		return nil
+	}
+	if s.failFast {
+		if !lim.Allow() {
+			return ErrRateLimited
+		}
+		return nil
+	}
+	return lim.Wait(ctx)
+}
+
+func SendRequest(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	return send(ctx, clientDo(client), req)
+}
+
+func send(ctx context.Context, do doFunc, req *http.Request) (*http.Response, error) {
+	resp, err := do(req.WithContext(ctx), 1, 0)
+	if err != nil && resp == nil {
 		select {
 		case <-ctx.Done():
 			err = ctx.Err()
 		default:
 		}
-		return resp, err
-	}
-	if resp.StatusCode != http.StatusOK {
-		errResp := datatypes.ErrorResponse{
-			HTTPStatus: resp.StatusCode,
-		}
-		_ = json.NewDecoder(resp.Body).Decode(&errResp)
-		err = errors.Join(err, &errResp)
 	}
 	return resp, err
 }
 
+// decodeErrorResponse decodes the error body of resp, which is left readable again for the caller.
+// Bodies that aren't an Apple error, e.g. an HTML page from a proxy, are kept in Body.
+func decodeErrorResponse(resp *http.Response) error {
+	errResp := datatypes.ErrorResponse{
+		HTTPStatus: resp.StatusCode,
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.ErrorCode == 0 {
+		errResp.HTTPStatus = resp.StatusCode
+		errResp.Body = body
+	}
+	return &errResp
+}
+
 func SendAndRetry(ctx context.Context, client *http.Client, req *http.Request, bo Backoff) (*http.Response, error) {
-	return sendAndRetry(ctx, client, req, bo)
+	return sendAndRetry(ctx, clientDo(client), req, bo, nil)
 }
 
-func sendAndRetry(ctx context.Context, client *http.Client, req *http.Request, bo Backoff) (*http.Response, error) {
-	if client == nil {
-		client = http.DefaultClient
-	}
+// MaxRetryAfter is the longest Retry-After waited for before retrying, a response asking for longer is
+// returned to the caller instead.
+const MaxRetryAfter = time.Minute
+
+// sendAndRetry retries req as long as policy allows, pausing with bo, or with the server's
+// Retry-After on 429 and 503 up to MaxRetryAfter. Bodies are rewound through req.GetBody, a request
+// whose body can't be rewound is sent only once.
+func sendAndRetry(ctx context.Context, do doFunc, req *http.Request, bo Backoff, policy RetryPolicy) (*http.Response, error) {
 	if bo == nil {
 		bo = httputils.NewBackoffImpl()
 	}
+	if policy == nil {
+		policy = &DefaultRetryPolicy{}
+	}
 
 	var (
-		needRetry bool
-		resp      *http.Response
-		err       error
-		interval  time.Duration
+		resp     *http.Response
+		err      error
+		interval time.Duration
+		start    = time.Now()
 	)
-	for {
+	for attempt := 1; ; attempt++ {
 		t := time.NewTimer(interval)
 		select {
 		case <-ctx.Done():
@@ -84,12 +265,34 @@ func sendAndRetry(ctx context.Context, client *http.Client, req *http.Request, b
 			return resp, ctx.Err()
 		}
 
-		resp, err = client.Do(req.WithContext(ctx))
-		if needRetry, err = shouldRetry(resp, err); !needRetry {
+		r := req
+		if attempt > 1 {
+			if r, err = rewindBody(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err = do(r.WithContext(ctx), attempt, interval)
+		if err == nil {
+			break
+		}
+		// the policy is asked about the time the retry would start, after the server's Retry-After
+		elapsed := time.Since(start)
+		wait, hasRetryAfter := retryAfter(resp)
+		if hasRetryAfter {
+			if wait > MaxRetryAfter {
+				break
+			}
+			elapsed += wait
+		}
+		if !canRewindBody(req) || !policy.Retry(attempt, elapsed, resp, err) {
 			break
 		}
 
 		interval = bo.Pause()
+		if hasRetryAfter {
+			interval = wait
+		}
 		if resp != nil && resp.Body != nil {
 			resp.Body.Close()
 		}
@@ -97,32 +300,31 @@ func sendAndRetry(ctx context.Context, client *http.Client, req *http.Request, b
 	return resp, err
 }
 
-func shouldRetry(resp *http.Response, err error) (bool, error) {
-	if err == nil && resp.StatusCode == http.StatusOK {
-		return false, err
-	}
-
-	if http.StatusInternalServerError <= resp.StatusCode && resp.StatusCode <= 599 {
-		return true, err
-	}
+func canRewindBody(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
 
-	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout {
-		return true, err
+// rewindBody returns a copy of req with a fresh body from req.GetBody.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
 	}
-
-	if errors.Is(err, io.ErrUnexpectedEOF) {
-		return true, err
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
 	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
 
-	errResp := datatypes.ErrorResponse{
-		HTTPStatus: resp.StatusCode,
+// retryAfter returns the delay requested by the server for 429 and 503 responses.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
 	}
-	_ = json.NewDecoder(resp.Body).Decode(&errResp)
-	err = errors.Join(err, &errResp)
-	// see https://developer.apple.com/documentation/appstoreserverapi/error_codes
-	if errResp.ErrorCode == 4040002 || errResp.ErrorCode == 4040004 || errResp.ErrorCode == 5000001 ||
-		errResp.ErrorCode == 4040006 {
-		return true, err
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
 	}
-	return false, err
+	return httputils.RetryAfter(resp)
 }
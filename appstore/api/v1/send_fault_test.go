@@ -0,0 +1,143 @@
+package appstoreapi_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	appstoreapi "github.com/gh73962/appleapis/appstore/api/v1"
+	"github.com/gh73962/appleapis/appstore/api/v1/appstoretest"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+type fixedBackoff time.Duration
+
+func (b fixedBackoff) Pause() time.Duration { return time.Duration(b) }
+
+func TestSendFaults(t *testing.T) {
+	const pause = 10 * time.Millisecond
+	tests := []struct {
+		name       string
+		steps      []appstoretest.Step
+		fallback   appstoretest.Step
+		opts       []appstoreapi.CallOption
+		wantErr    error
+		wantCode   int64
+		wantCalls  int
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "connection reset",
+			steps:      []appstoretest.Step{appstoretest.ConnReset()},
+			wantCalls:  2,
+			wantDelays: []time.Duration{pause},
+		},
+		{
+			name:      "unexpected EOF before headers",
+			steps:     []appstoretest.Step{appstoretest.UnexpectedEOF()},
+			wantCalls: 2,
+		},
+		{
+			name:       "service unavailable twice",
+			steps:      []appstoretest.Step{appstoretest.Status(http.StatusServiceUnavailable), appstoretest.Status(http.StatusServiceUnavailable)},
+			wantCalls:  3,
+			wantDelays: []time.Duration{pause, pause},
+		},
+		{
+			name:      "persistent internal error",
+			fallback:  appstoretest.Status(http.StatusInternalServerError),
+			wantErr:   &datatypes.ErrorResponse{},
+			wantCalls: 3,
+		},
+		{
+			name:      "retryable apple error",
+			steps:     []appstoretest.Step{appstoretest.AppleError(4040002)},
+			wantCalls: 2,
+		},
+		{
+			name:      "final apple error",
+			steps:     []appstoretest.Step{appstoretest.AppleError(datatypes.ErrorCodeTransactionIDNotFound)},
+			wantCode:  datatypes.ErrorCodeTransactionIDNotFound,
+			wantCalls: 1,
+		},
+		{
+			name:       "retry after overrides backoff",
+			steps:      []appstoretest.Step{appstoretest.RetryAfter(http.StatusTooManyRequests, 0)},
+			opts:       []appstoreapi.CallOption{appstoreapi.CallBackoff(func() appstoreapi.Backoff { return fixedBackoff(time.Hour) })},
+			wantCalls:  2,
+			wantDelays: []time.Duration{0},
+		},
+		{
+			name:      "slow headers past the attempt timeout",
+			steps:     []appstoretest.Step{appstoretest.SlowHeaders(time.Second, appstoretest.OK(`{}`))},
+			opts:      []appstoreapi.CallOption{appstoreapi.CallAttemptTimeout(20 * time.Millisecond)},
+			wantCalls: 2,
+		},
+		{
+			name:      "call deadline during slow headers",
+			fallback:  appstoretest.SlowHeaders(time.Second, appstoretest.OK(`{}`)),
+			opts:      []appstoreapi.CallOption{appstoreapi.CallTimeout(30 * time.Millisecond)},
+			wantErr:   context.DeadlineExceeded,
+			wantCalls: 1,
+		},
+		{
+			name:      "truncated body",
+			steps:     []appstoretest.Step{appstoretest.TruncatedBody(`{"bundleId":"com.example.app"}`)},
+			fallback:  appstoretest.OK(`{"bundleId":"com.example.app"}`),
+			wantCalls: 2,
+		},
+		{
+			name:      "truncated body on every attempt",
+			fallback:  appstoretest.TruncatedBody(`{"bundleId":"com.example.app"}`),
+			wantErr:   io.ErrUnexpectedEOF,
+			wantCalls: 3,
+		},
+		{
+			name:      "retries disabled for the call",
+			steps:     []appstoretest.Step{appstoretest.Status(http.StatusServiceUnavailable)},
+			opts:      []appstoreapi.CallOption{appstoreapi.CallRetryPolicy(appstoreapi.NoRetry)},
+			wantErr:   &datatypes.ErrorResponse{},
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := appstoretest.NewFaultTransport(tt.steps...)
+			ft.Default = tt.fallback
+			s := appstoreapi.NewAppStoreService(context.Background(),
+				appstoreapi.WithHTTPClient(ft.Client()),
+				appstoreapi.WithRetryPolicy(&appstoreapi.DefaultRetryPolicy{MaxAttempts: 3}))
+			s.NewBackoff = func() appstoreapi.Backoff { return fixedBackoff(pause) }
+
+			_, err := s.AllSubscriptionStatuses(context.Background(), "bearer", "1", 0, tt.opts...)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil && tt.wantCode == 0 {
+					t.Errorf("AllSubscriptionStatuses() error = %v", err)
+				}
+			case *datatypes.ErrorResponse:
+				if !errors.As(err, &want) {
+					t.Errorf("AllSubscriptionStatuses() error = %v, want an ErrorResponse", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("AllSubscriptionStatuses() error = %v, want %v", err, want)
+				}
+			}
+			if tt.wantCode != 0 {
+				var errResp *datatypes.ErrorResponse
+				if !errors.As(err, &errResp) || errResp.ErrorCode != tt.wantCode {
+					t.Errorf("AllSubscriptionStatuses() error = %v, want code %d", err, tt.wantCode)
+				}
+			}
+
+			ft.AssertAttempts(t, tt.wantCalls)
+			if tt.wantDelays != nil {
+				ft.AssertDelays(t, 50*time.Millisecond, tt.wantDelays...)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package appstoreapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+func TestSendAndRetryHonorsRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := SendAndRetry(context.Background(), srv.Client(), req, &constantBackoff{})
+	if err != nil {
+		t.Fatalf("SendAndRetry() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("SendAndRetry() calls = %d, want 2", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("SendAndRetry() retried after %v, want at least 1s", elapsed)
+	}
+}
+
+// elapsedPolicy records the elapsed time it is asked about and retries while it stays under max.
+type elapsedPolicy struct {
+	max     time.Duration
+	elapsed []time.Duration
+}
+
+func (p *elapsedPolicy) Retry(_ int, elapsed time.Duration, _ *http.Response, _ error) bool {
+	p.elapsed = append(p.elapsed, elapsed)
+	return elapsed < p.max
+}
+
+func TestSendAndRetryLongRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		policy     RetryPolicy
+		wantCalls  int32
+	}{
+		{
+			name:       "past MaxRetryAfter",
+			retryAfter: "3600",
+			policy:     &DefaultRetryPolicy{},
+			wantCalls:  1,
+		},
+		{
+			name:       "past MaxElapsedTime",
+			retryAfter: "10",
+			policy:     &DefaultRetryPolicy{MaxElapsedTime: 5 * time.Second},
+			wantCalls:  1,
+		},
+		{
+			name:       "past the bound of a custom policy",
+			retryAfter: "10",
+			policy:     &elapsedPolicy{max: 5 * time.Second},
+			wantCalls:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Retry-After", tt.retryAfter)
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			resp, err := sendAndRetry(ctx, clientDo(srv.Client()), req, &constantBackoff{}, tt.policy)
+			var errResp *datatypes.ErrorResponse
+			if !errors.As(err, &errResp) || errResp.HTTPStatus != http.StatusTooManyRequests || ctx.Err() != nil {
+				t.Fatalf("sendAndRetry() error = %v, want the 429 without waiting", err)
+			}
+			resp.Body.Close()
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("sendAndRetry() calls = %d, want %d", got, tt.wantCalls)
+			}
+			if p, ok := tt.policy.(*elapsedPolicy); ok && (len(p.elapsed) != 1 || p.elapsed[0] < 10*time.Second) {
+				t.Errorf("Retry() elapsed = %v, want the Retry-After included", p.elapsed)
+			}
+		})
+	}
+}
+
+func TestServiceRateLimitFailFast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithRateLimit(EndpointLookUpOrderID, 1, time.Hour, 1),
+		WithRateLimitFailFast(),
+	)
+	s.BasePath = srv.URL + "/"
+
+	if _, err := s.LookUpOrderID(context.Background(), "bearer", "order"); err != nil {
+		t.Fatalf("LookUpOrderID() first call error = %v", err)
+	}
+	if _, err := s.LookUpOrderID(context.Background(), "bearer", "order"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("LookUpOrderID() second call error = %v, want %v", err, ErrRateLimited)
+	}
+	if _, err := s.TransactionHistory(context.Background(), "bearer", "1"); err != nil {
+		t.Errorf("TransactionHistory() error = %v, want unlimited", err)
+	}
+}
+
+func TestServiceRateLimitWaitOutsideAttemptTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	const per = 100 * time.Millisecond
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithRateLimit(EndpointLookUpOrderID, 1, per, 1),
+	)
+	s.BasePath = srv.URL + "/"
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err := s.LookUpOrderID(context.Background(), "bearer", "order", CallAttemptTimeout(per/4)); err != nil {
+			t.Fatalf("LookUpOrderID() call %d error = %v, want the token waited for", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < per/2 {
+		t.Errorf("LookUpOrderID() returned after %v, want a wait for the token", elapsed)
+	}
+}
+
+func TestSendAndRetryRewindsBody(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"startDate":1}` {
+			t.Errorf("attempt %d body = %q", atomic.LoadInt32(&calls)+1, body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"startDate":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := SendAndRetry(context.Background(), srv.Client(), req, &constantBackoff{})
+	if err != nil {
+		t.Fatalf("SendAndRetry() error = %v", err)
+	}
+	resp.Body.Close()
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("SendAndRetry() calls = %d, want 3", got)
+	}
+}
+
+func TestServiceRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		policy    RetryPolicy
+		opts      []CallOption
+		wantCalls int32
+	}{
+		{
+			name:      "max attempts",
+			ctx:       context.Background(),
+			policy:    &DefaultRetryPolicy{MaxAttempts: 2},
+			wantCalls: 2,
+		},
+		{
+			name:      "per call opt out",
+			ctx:       ContextWithRetryPolicy(context.Background(), NoRetry),
+			policy:    &DefaultRetryPolicy{MaxAttempts: 5},
+			wantCalls: 1,
+		},
+		{
+			name:      "call option over context",
+			ctx:       ContextWithRetryPolicy(context.Background(), NoRetry),
+			policy:    &DefaultRetryPolicy{MaxAttempts: 5},
+			opts:      []CallOption{CallRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 3})},
+			wantCalls: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer srv.Close()
+
+			s := NewAppStoreService(tt.ctx, WithHTTPClient(srv.Client()), WithRetryPolicy(tt.policy))
+			s.BasePath = srv.URL + "/"
+			s.NewBackoff = func() Backoff { return &constantBackoff{} }
+
+			if _, err := s.TestNotification(tt.ctx, "bearer", tt.opts...); err == nil {
+				t.Errorf("TestNotification() error = nil, want 502")
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("TestNotification() calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestServiceConcurrentRetries(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		retried := seen[r.URL.Path]
+		seen[r.URL.Path] = true
+		mu.Unlock()
+		if !retried {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var created int32
+	s := NewAppStoreService(context.Background(),
+		WithHTTPClient(srv.Client()),
+		WithRetry(time.Millisecond, 10*time.Millisecond),
+		WithBackoffStrategy(BackoffDecorrelatedJitter),
+	)
+	s.BasePath = srv.URL + "/"
+	factory := s.NewBackoff
+	s.NewBackoff = func() Backoff {
+		atomic.AddInt32(&created, 1)
+		return factory()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.LookUpOrderID(context.Background(), "bearer", strconv.Itoa(i)); err != nil {
+				t.Errorf("LookUpOrderID(%d) error = %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&created); got != 20 {
+		t.Errorf("Backoff created %d times, want one per request", got)
+	}
+}
+
+func TestServiceDeprecatedBackOff(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()), WithRetry(time.Hour, time.Hour))
+	s.BasePath = srv.URL + "/"
+	s.BackOff = constantBackoff{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.LookUpOrderID(ctx, "bearer", "order"); err != nil {
+		t.Fatalf("LookUpOrderID() error = %v, want BackOff to replace the hour long default", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("LookUpOrderID() calls = %d, want 2", got)
+	}
+}
+
+type constantBackoff struct{}
+
+func (constantBackoff) Pause() time.Duration { return time.Millisecond }
+
+func TestServiceResponseMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Apple-Request-Uuid", "b2c4f0e4")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errorCode":4040005,"errorMessage":"Original transaction id not found."}`))
+	}))
+	defer srv.Close()
+
+	s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()))
+	s.BasePath = srv.URL + "/"
+
+	meta := ResponseMeta{CaptureBody: true}
+	_, err := s.AllSubscriptionStatuses(ContextWithResponseMeta(context.Background(), &meta), "bearer", "1", 0)
+	if err == nil {
+		t.Fatal("AllSubscriptionStatuses() error = nil, want 404")
+	}
+	if meta.StatusCode != http.StatusNotFound || meta.Attempts != 1 || meta.RequestID() != "b2c4f0e4" {
+		t.Errorf("ResponseMeta = %+v", meta)
+	}
+	if !strings.Contains(string(meta.Body), "4040005") {
+		t.Errorf("ResponseMeta.Body = %q", meta.Body)
+	}
+}
+
+func TestServiceExpectedStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantBody  string
+		wantCalls int32
+	}{
+		{
+			name:      "accepted",
+			status:    http.StatusAccepted,
+			wantCalls: 1,
+		},
+		{
+			name:      "ok",
+			status:    http.StatusOK,
+			wantCalls: 1,
+		},
+		{
+			name:      "no content",
+			status:    http.StatusNoContent,
+			wantCalls: 1,
+		},
+		{
+			name:      "proxy error page",
+			status:    http.StatusBadGateway,
+			body:      "<html><body>502 Bad Gateway</body></html>",
+			wantBody:  "502 Bad Gateway",
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s := NewAppStoreService(context.Background(), WithHTTPClient(srv.Client()),
+				WithRetryPolicy(&DefaultRetryPolicy{MaxAttempts: 2}))
+			s.BasePath = srv.URL + "/"
+			s.NewBackoff = func() Backoff { return &constantBackoff{} }
+
+			err := s.SendConsumptionInformation(context.Background(), "bearer", "1", &datatypes.ConsumptionRequest{CustomerConsented: true})
+			if tt.wantBody != "" {
+				var errResp *datatypes.ErrorResponse
+				if !errors.As(err, &errResp) || !strings.Contains(string(errResp.Body), tt.wantBody) ||
+					!strings.Contains(err.Error(), tt.wantBody) {
+					t.Errorf("SendConsumptionInformation() error = %v, want body %q", err, tt.wantBody)
+				}
+			}
+			if tt.wantBody == "" && err != nil {
+				t.Errorf("SendConsumptionInformation() error = %v", err)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("SendConsumptionInformation() calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -3,20 +3,72 @@ package appstoreapi
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/gh73962/appleapis/appstore/api/internal/httputils"
+	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
+)
+
+var (
+	// ErrRateLimited is returned when the client-side rate limit of an endpoint is exhausted.
+	ErrRateLimited = httputils.ErrRateLimited
+	// ErrResponseTooLarge is returned when a response body exceeds the limit set by WithMaxResponseBytes.
+	ErrResponseTooLarge = httputils.ErrResponseTooLarge
 )
 
+// DefaultMaxResponseBytes caps response bodies unless WithMaxResponseBytes says otherwise.
+const DefaultMaxResponseBytes = 10 << 20
+
 // Backoff see https://aws.amazon.com/cn/blogs/architecture/exponential-backoff-and-jitter/
+// A Backoff holds the state of one request and is not shared between requests.
 type Backoff interface {
 	Pause() time.Duration
 }
 
+// BackoffFactory creates the Backoff of a request.
+type BackoffFactory func() Backoff
+
+// BackoffStrategy selects a built-in Backoff.
+type BackoffStrategy int
+
+const (
+	BackoffFullJitter         BackoffStrategy = iota // random between 0 and the exponential delay
+	BackoffEqualJitter                               // half the exponential delay plus a random half
+	BackoffDecorrelatedJitter                        // random between initial and three times the previous delay
+	BackoffConstant                                  // always the initial delay
+)
+
 type Service struct {
 	client    *http.Client
 	BasePath  string
 	UserAgent string
-	BackOff   Backoff
-	NeedRetry bool
+	// BackOff, when set, is used by every request in place of NewBackoff.
+	//
+	// Deprecated: a Backoff holds the state of one request, set NewBackoff instead.
+	BackOff     Backoff
+	NewBackoff  BackoffFactory // creates the Backoff of each request, default from the Options
+	NeedRetry   bool
+	RetryPolicy RetryPolicy // used when NeedRetry, default DefaultRetryPolicy
+
+	maxResponseBytes int64
+	timeout          time.Duration // of calls not setting CallTimeout
+	attemptTimeout   time.Duration // of calls not setting CallAttemptTimeout
+	env              datatypes.Environment
+	resolver         EnvironmentResolver
+	err              error // configuration error returned by every call
+	limiters         map[Endpoint]*httputils.TokenBucket
+	failFast         bool
+	breakers         *circuitBreakers
+	interceptors     []Interceptor
+	callInterceptors []Interceptor
+	flights          *flightGroup // nil unless coalescing
+}
+
+// NewTransport returns the tuned transport used when no http.Client is given, to build a custom http.Client on.
+// proxy may be nil for none.
+func NewTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	return httputils.NewTransport(proxy)
 }
 
 func NewAppStoreService(ctx context.Context, options ...Option) *Service {
@@ -25,12 +77,36 @@ func NewAppStoreService(ctx context.Context, options ...Option) *Service {
 		opt(&clientOpt)
 	}
 	s := Service{
-		client:    clientOpt.HTTPClient,
-		BasePath:  clientOpt.GetBasePath(),
-		UserAgent: clientOpt.GetUserAgent(),
-		BackOff:   clientOpt.GetBackoff(),
-		NeedRetry: clientOpt.NeedRetry,
+		client:           clientOpt.GetHTTPClient(),
+		maxResponseBytes: clientOpt.GetMaxResponseBytes(),
+		timeout:          clientOpt.Timeout,
+		attemptTimeout:   clientOpt.AttemptTimeout,
+		env:              clientOpt.GetEnvironment(),
+		UserAgent:        clientOpt.GetUserAgent(),
+		NewBackoff:       clientOpt.GetBackoffFactory(),
+		NeedRetry:        clientOpt.NeedRetry,
+		RetryPolicy:      clientOpt.RetryPolicy,
+		limiters:         clientOpt.GetRateLimiters(),
+		failFast:         clientOpt.RateLimitFailFast,
+		breakers:         clientOpt.getCircuitBreakers(),
+		interceptors:     clientOpt.Interceptors,
+		callInterceptors: clientOpt.CallInterceptors,
+	}
+	if s.resolver, s.err = clientOpt.GetResolver(); s.err == nil {
+		s.BasePath, s.err = s.resolver.BaseURL(s.env)
+	}
+	if clientOpt.Coalesce {
+		s.flights = &flightGroup{}
+	}
+	if l := clientOpt.GetLogger(); l != nil {
+		s.callInterceptors = append([]Interceptor{callLogger(l)}, s.callInterceptors...)
+		s.interceptors = append([]Interceptor{attemptLogger(l)}, s.interceptors...)
 	}
 
 	return &s
 }
+
+// Environment returns the environment calls are sent to, unless CallEnvironment sets another for a call.
+func (s *Service) Environment() datatypes.Environment {
+	return s.env
+}
@@ -2,36 +2,35 @@ package appstoreapi
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
 )
 
 // AllSubscriptionStatuses see https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
 func (s *Service) AllSubscriptionStatuses(ctx context.Context, bearer, transactionID string,
-	status datatypes.SubscriptionStatus) (*datatypes.StatusResponse, error) {
-	url := s.BasePath + "subscriptions/" + transactionID
-	if status > 0 {
-		url += fmt.Sprintf("?status=%d", status)
-	}
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
+	status datatypes.SubscriptionStatus, opts ...CallOption) (*datatypes.StatusResponse, error) {
+	if err := validateTransactionID(transactionID); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
-	resp, err := s.Do(ctx, req)
-	if err != nil {
-		return nil, err
+	var query url.Values
+	if status > 0 {
+		query = url.Values{"status": {strconv.Itoa(int(status))}}
 	}
-	defer resp.Body.Close()
 
 	var rsp datatypes.StatusResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointAllSubscriptionStatuses,
+		method:   http.MethodGet,
+		path:     []string{"subscriptions", transactionID},
+		query:    query,
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
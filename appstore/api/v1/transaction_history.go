@@ -2,7 +2,6 @@ package appstoreapi
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
@@ -10,22 +9,20 @@ import (
 
 // TransactionHistory see https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
 // TODO Query Parameters
-func (s *Service) TransactionHistory(ctx context.Context, bearer, transactionID string) (*datatypes.HistoryResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, s.BasePath+"history/"+transactionID, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
+func (s *Service) TransactionHistory(ctx context.Context, bearer, transactionID string, opts ...CallOption) (*datatypes.HistoryResponse, error) {
+	if err := validateTransactionID(transactionID); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
 	var rsp datatypes.HistoryResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointTransactionHistory,
+		method:   http.MethodGet,
+		path:     []string{"history", transactionID},
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -1,31 +1,27 @@
 package appstoreapi
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
 
 	"github.com/gh73962/appleapis/appstore/api/v1/datatypes"
 )
 
 // TransactionInfo see https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
-func (s *Service) TransactionInfo(ctx context.Context, bearer, transactionID string) (*datatypes.JWSTransaction, error) {
-	req, err := http.NewRequest(http.MethodGet, s.BasePath+"transactions/"+transactionID, nil)
-	if err != nil {
+func (s *Service) TransactionInfo(ctx context.Context, bearer, transactionID string, opts ...CallOption) (*datatypes.JWSTransaction, error) {
+	if err := validateTransactionID(transactionID); err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
 
 	var rsp datatypes.TransactionInfoResponse
-	if err = json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+	err := s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointTransactionInfo,
+		method:   http.MethodGet,
+		path:     []string{"transactions", transactionID},
+	}, &rsp)
+	if err != nil {
 		return nil, err
 	}
 
@@ -33,27 +29,20 @@ func (s *Service) TransactionInfo(ctx context.Context, bearer, transactionID str
 }
 
 // SendConsumptionInformation see https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
-func (s *Service) SendConsumptionInformation(ctx context.Context, bearer, transactionID string, cr *datatypes.ConsumptionRequest) error {
-	var buff bytes.Buffer
-	if err := json.NewEncoder(&buff).Encode(cr); err != nil {
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPut, s.BasePath+"transactions/consumption/"+transactionID, &buff)
-	if err != nil {
+func (s *Service) SendConsumptionInformation(ctx context.Context, bearer, transactionID string, cr *datatypes.ConsumptionRequest, opts ...CallOption) error {
+	if err := validateTransactionID(transactionID); err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+bearer)
-	req.Header.Set("User-Agent", s.UserAgent)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.Do(ctx, req)
-	if err != nil {
+	if err := validateConsumption(cr); err != nil {
 		return err
 	}
-	if resp != nil && resp.Body != nil {
-		resp.Body.Close()
-	}
-
-	return nil
+	ctx, cancel := s.withCallOptions(ctx, opts)
+	defer cancel()
+
+	return s.call(ctx, bearer, apiRequest{
+		endpoint: EndpointSendConsumptionInfo,
+		method:   http.MethodPut,
+		path:     []string{"transactions", "consumption", transactionID},
+		body:     cr,
+	}, nil)
 }
@@ -32,14 +32,28 @@ func DecodeSignedData(data string) ([]byte, []byte, string, error) {
 		return nil, nil, "", errors.New("invalid signed data")
 	}
 
-	header, err := base64.RawStdEncoding.DecodeString(array[0])
+	header, err := decodeSegment(array[0])
 	if err != nil {
 		return nil, nil, "", err
 	}
-	payload, err := base64.RawStdEncoding.DecodeString(array[1])
+	payload, err := decodeSegment(array[1])
 	if err != nil {
 		return nil, nil, "", err
 	}
 
 	return header, payload, array[2], nil
 }
+
+// decodeSegment decodes a base64url segment, the encoding of JWS, padded or not. Segments in the standard
+// alphabet, decoded by the earlier versions, are still accepted.
+func decodeSegment(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		return b, nil
+	}
+	if b, stdErr := base64.RawStdEncoding.DecodeString(s); stdErr == nil {
+		return b, nil
+	}
+	return nil, err
+}
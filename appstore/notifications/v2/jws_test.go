@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDecodeToJWSNotification(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","x5c":["leaf"]}`))
+	payload := []byte(`{"notificationType":"DID_RENEW","notificationUUID":"~~~?"}`)
+	tests := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{"base64url", base64.RawURLEncoding.EncodeToString(payload), false},
+		{"padded base64url", base64.URLEncoding.EncodeToString(payload), false},
+		{"standard alphabet", base64.RawStdEncoding.EncodeToString(payload), false},
+		{"invalid base64", "!!!", true},
+	}
+	if !strings.ContainsAny(tests[0].payload, "-_") {
+		t.Fatalf("payload %s has no base64url specific character", tests[0].payload)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeToJWSNotification(header + "." + tt.payload + ".sig")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeToJWSNotification() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Header.Alg != "ES256" || got.Payload.NotificationType != DidRenew ||
+				got.Payload.NotificationUUID != "~~~?" || got.Signature != "sig" {
+				t.Errorf("DecodeToJWSNotification() = %+v", got)
+			}
+		})
+	}
+}
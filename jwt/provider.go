@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenLifetime is the validity of the tokens signed by a TokenProvider, Apple rejects more than 60 minutes.
+	tokenLifetime = 30 * time.Minute
+	// tokenRefresh is the time before its expiration a token is replaced.
+	tokenRefresh = 5 * time.Minute
+)
+
+// TokenProvider signs bearer tokens of an app, reusing one until shortly before it expires. It is safe for concurrent use.
+type TokenProvider struct {
+	Issuer   string
+	KeyID    string
+	BundleID string
+	Key      *ecdsa.PrivateKey
+	Now      func() time.Time // default time.Now
+
+	mu      sync.Mutex
+	bearer  string
+	expires time.Time
+}
+
+// NewTokenProvider returns a TokenProvider for the app bundleID, signing with key of keyID issued by issuer.
+func NewTokenProvider(issuer, keyID, bundleID string, key *ecdsa.PrivateKey) *TokenProvider {
+	return &TokenProvider{Issuer: issuer, KeyID: keyID, BundleID: bundleID, Key: key}
+}
+
+// Token returns a bearer token for the App Store Server API.
+func (p *TokenProvider) Token() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+	if p.bearer != "" && now.Before(p.expires.Add(-tokenRefresh)) {
+		return p.bearer, nil
+	}
+
+	claims := NewClaims(p.Issuer, p.BundleID)
+	claims.IssuedAt = now.Unix()
+	claims.ExpirationTime = now.Add(tokenLifetime).Unix()
+	_, bearer, err := NewToken(p.KeyID, claims, p.Key)
+	if err != nil {
+		return "", err
+	}
+	p.bearer, p.expires = bearer, time.Unix(claims.ExpirationTime, 0)
+	return p.bearer, nil
+}